@@ -19,7 +19,7 @@ func init() {
 	getCmd.Flags().StringVarP(&getOpts.Bias, "bias", "b", "as-is", "set the line bias.")
 	getCmd.Flags().IntVar(&getOpts.AbiV, "abiv", 0, "use specified ABI version.")
 	getCmd.Flags().MarkHidden("abiv")
-	getCmd.SetHelpTemplate(getCmd.HelpTemplate() + extendedGetHelp)
+	getCmd.SetHelpTemplate(getCmd.HelpTemplate() + extendedGetHelp + chipHelp)
 	rootCmd.AddCommand(getCmd)
 }
 
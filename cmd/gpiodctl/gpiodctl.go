@@ -24,6 +24,19 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+var chipHelp = `
+Chips:
+  A chip may be identified by:
+    name:         e.g. gpiochip0
+    number:       e.g. 0
+    path:         e.g. /dev/gpiochip0
+    label:        e.g. pinctrl-bcm2711 or label:pinctrl-bcm2711
+    device:       the sysfs path of the device providing the chip,
+                  e.g. /sys/devices/platform/soc/fe200000.gpio
+    of node:      the device tree node of the device providing the chip,
+                  e.g. of:/soc/gpio@7e200000 or /proc/device-tree/soc/gpio@7e200000
+`
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -16,6 +16,7 @@ import (
 func init() {
 	infoCmd.Flags().IntVar(&infoOpts.AbiV, "abiv", 0, "use specified ABI version.")
 	infoCmd.Flags().MarkHidden("abiv")
+	infoCmd.SetHelpTemplate(infoCmd.HelpTemplate() + chipHelp)
 	rootCmd.AddCommand(infoCmd)
 }
 
@@ -109,6 +110,12 @@ func printLineInfo(li gpiod.LineInfo) {
 		attrs = append(attrs,
 			fmt.Sprintf("debounce-period=%s", li.Config.DebouncePeriod))
 	}
+	switch li.Config.EventClock {
+	case gpiod.LineEventClockRealtime:
+		attrs = append(attrs, "event-clock=realtime")
+	case gpiod.LineEventClockHTE:
+		attrs = append(attrs, "event-clock=hte")
+	}
 	attrstr := ""
 	if len(attrs) > 0 {
 		attrstr = "[" + strings.Join(attrs, ",") + "]"
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -25,7 +26,7 @@ func init() {
 	monCmd.Flags().BoolVarP(&monOpts.Quiet, "quiet", "q", false, "don't display event details")
 	monCmd.Flags().IntVar(&monOpts.AbiV, "abiv", 0, "use specified ABI version.")
 	monCmd.Flags().MarkHidden("abiv")
-	monCmd.SetHelpTemplate(monCmd.HelpTemplate() + extendedMonHelp)
+	monCmd.SetHelpTemplate(monCmd.HelpTemplate() + extendedMonHelp + chipHelp)
 	rootCmd.AddCommand(monCmd)
 }
 
@@ -79,10 +80,7 @@ func mon(cmd *cobra.Command, args []string) error {
 	}
 	defer c.Close()
 	evtchan := make(chan gpiod.LineEvent)
-	eh := func(evt gpiod.LineEvent) {
-		evtchan <- evt
-	}
-	opts := makeMonOpts(eh)
+	opts := makeMonOpts(gpiod.WithEventChannel(context.Background(), evtchan))
 	l, err := c.RequestLines(oo, opts...)
 	if err != nil {
 		return fmt.Errorf("error requesting GPIO lines: %s", err)
@@ -101,7 +99,7 @@ func monWait(evtchan <-chan gpiod.LineEvent) {
 		select {
 		case evt := <-evtchan:
 			if !monOpts.Quiet {
-				t := time.Now()
+				t := evt.Time()
 				edge := "rising"
 				if evt.Type == gpiod.LineEventFallingEdge {
 					edge = "falling"
@@ -132,8 +130,8 @@ func monWait(evtchan <-chan gpiod.LineEvent) {
 	}
 }
 
-func makeMonOpts(eh gpiod.EventHandler) []gpiod.LineReqOption {
-	opts := []gpiod.LineReqOption{gpiod.WithEventHandler(eh)}
+func makeMonOpts(ech gpiod.EventChannelOption) []gpiod.LineReqOption {
+	opts := []gpiod.LineReqOption{ech}
 	if monOpts.ActiveLow {
 		opts = append(opts, gpiod.AsActiveLow)
 	}
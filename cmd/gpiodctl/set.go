@@ -27,7 +27,7 @@ func init() {
 	setCmd.Flags().StringVarP(&setOpts.Time, "time", "t", "", "wait for a period of time then exit.")
 	setCmd.Flags().IntVar(&setOpts.AbiV, "abiv", 0, "use specified ABI version.")
 	setCmd.Flags().MarkHidden("abiv")
-	setCmd.SetHelpTemplate(setCmd.HelpTemplate() + extendedSetHelp)
+	setCmd.SetHelpTemplate(setCmd.HelpTemplate() + extendedSetHelp + chipHelp)
 	rootCmd.AddCommand(setCmd)
 }
 
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/taemon1337/gpiod"
+)
+
+func init() {
+	waitCmd.Flags().BoolVarP(&waitOpts.ActiveLow, "active-low", "l", false, "treat the line state as active low")
+	waitCmd.Flags().StringVarP(&waitOpts.Bias, "bias", "b", "as-is", "set the line bias")
+	waitCmd.Flags().DurationVarP(&waitOpts.DebouncePeriod, "debounce-period", "d", 0, "set the line debounce period")
+	waitCmd.Flags().DurationVarP(&waitOpts.Timeout, "timeout", "t", 0, "give up after the timeout (default wait indefinitely)")
+	waitCmd.Flags().BoolVarP(&waitOpts.Quiet, "quiet", "q", false, "don't display the offset of the line reaching its state")
+	waitCmd.Flags().IntVar(&waitOpts.AbiV, "abiv", 0, "use specified ABI version.")
+	waitCmd.Flags().MarkHidden("abiv")
+	waitCmd.SetHelpTemplate(waitCmd.HelpTemplate() + extendedWaitHelp + chipHelp)
+	rootCmd.AddCommand(waitCmd)
+}
+
+var extendedWaitHelp = `
+Biases:
+  as-is:        leave bias unchanged
+  disable:      disable bias
+  pull-up:      enable pull-up
+  pull-down:    enable pull-down
+
+Exit status:
+  0:            a line reached its state
+  1:            an error occurred
+  2:            the timeout expired before any line reached its state
+`
+
+// waitTimeoutExitCode is the exit code used when the timeout expires.
+const waitTimeoutExitCode = 2
+
+var (
+	waitCmd = &cobra.Command{
+		Use:                   "wait [flags] <chip> <offset1>=<state1>...",
+		Short:                 "Wait for a line or lines to reach a state",
+		Long:                  `Wait until any of the lines on a GPIO chip reaches its state, and print the offset of that line.`,
+		Args:                  cobra.MinimumNArgs(2),
+		RunE:                  wait,
+		DisableFlagsInUseLine: true,
+	}
+	waitOpts = struct {
+		ActiveLow      bool
+		Bias           string
+		DebouncePeriod time.Duration
+		Timeout        time.Duration
+		Quiet          bool
+		AbiV           int
+	}{}
+)
+
+func wait(cmd *cobra.Command, args []string) error {
+	offset, err := waitLines(args)
+	if err == context.DeadlineExceeded {
+		os.Exit(waitTimeoutExitCode)
+	}
+	if err != nil {
+		return err
+	}
+	if !waitOpts.Quiet {
+		fmt.Println(offset)
+	}
+	return nil
+}
+
+func waitLines(args []string) (int, error) {
+	name := args[0]
+	ll := []int(nil)
+	vv := map[int]int{}
+	for _, arg := range args[1:] {
+		o, v, err := parseLineValue(arg)
+		if err != nil {
+			return 0, err
+		}
+		ll = append(ll, o)
+		vv[o] = v
+	}
+	copts := []gpiod.ChipOption{gpiod.WithConsumer("gpiodctl-wait")}
+	if waitOpts.AbiV != 0 {
+		copts = append(copts, gpiod.WithABIVersion(waitOpts.AbiV))
+	}
+	c, err := gpiod.NewChip(name, copts...)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	l, err := c.RequestLines(ll, makeWaitOpts()...)
+	if err != nil {
+		return 0, fmt.Errorf("error requesting GPIO lines: %s", err)
+	}
+	defer l.Close()
+	ctx := context.Background()
+	if waitOpts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, waitOpts.Timeout)
+		defer cancel()
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return l.WaitForAny(ctx, vv)
+}
+
+func makeWaitOpts() []gpiod.LineReqOption {
+	opts := []gpiod.LineReqOption{
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(func(gpiod.LineEvent) {}),
+	}
+	if waitOpts.ActiveLow {
+		opts = append(opts, gpiod.AsActiveLow)
+	}
+	bias := strings.ToLower(waitOpts.Bias)
+	switch bias {
+	case "pull-up":
+		opts = append(opts, gpiod.WithPullUp)
+	case "pull-down":
+		opts = append(opts, gpiod.WithPullDown)
+	case "disable":
+		opts = append(opts, gpiod.WithBiasDisabled)
+	case "as-is":
+		fallthrough
+	default:
+	}
+	if waitOpts.DebouncePeriod != 0 {
+		opts = append(opts, gpiod.WithDebounce(waitOpts.DebouncePeriod))
+	}
+	return opts
+}
@@ -20,6 +20,7 @@ func init() {
 	watchCmd.Flags().BoolVarP(&watchOpts.Verbose, "verbose", "v", false, "display complete line info")
 	watchCmd.Flags().IntVar(&watchOpts.AbiV, "abiv", 0, "use specified ABI version.")
 	watchCmd.Flags().MarkHidden("abiv")
+	watchCmd.SetHelpTemplate(watchCmd.HelpTemplate() + chipHelp)
 	rootCmd.AddCommand(watchCmd)
 }
 
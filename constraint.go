@@ -0,0 +1,300 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"fmt"
+	"time"
+)
+
+// Constraint restricts the values that may be set on output lines.
+//
+// Constraints are provided to a request using WithConstraints, and are
+// checked by the SetValue family of methods, and by Reconfigure, before the
+// lines are set.
+type Constraint interface {
+	fmt.Stringer
+
+	// lines returns the lines covered by the constraint.
+	lines() []int
+
+	// check returns an ErrConstraintViolation if the transition violates
+	// the constraint.
+	check(t *transition) error
+}
+
+// MutuallyExclusiveConstraint prevents more than one of a set of lines being
+// active at the same time.
+type MutuallyExclusiveConstraint []int
+
+// MutuallyExclusive prevents more than one of the lines being active at the
+// same time, e.g. the high and low side switches of an H-bridge.
+//
+// Lines may always be set inactive, so a line must be set inactive before
+// another in the set may be set active.
+func MutuallyExclusive(lines ...int) MutuallyExclusiveConstraint {
+	return MutuallyExclusiveConstraint(append([]int(nil), lines...))
+}
+
+func (c MutuallyExclusiveConstraint) String() string {
+	return fmt.Sprintf("mutually exclusive lines %v", []int(c))
+}
+
+func (c MutuallyExclusiveConstraint) lines() []int {
+	return c
+}
+
+func (c MutuallyExclusiveConstraint) check(t *transition) error {
+	var on []int
+	activated := false
+	for _, o := range c {
+		if t.value(o) != 0 {
+			on = append(on, o)
+			if t.changes(o) {
+				activated = true
+			}
+		}
+	}
+	if activated && len(on) > 1 {
+		return ErrConstraintViolation{Constraint: c, Lines: on}
+	}
+	return nil
+}
+
+// DeadTimeConstraint requires a minimum period between one of a set of lines
+// being set inactive and another being set active.
+type DeadTimeConstraint struct {
+	Period time.Duration
+	Lines  []int
+}
+
+// DeadTime requires a line be inactive for at least the period before any
+// other of the lines may be set active.
+//
+// This is typically combined with MutuallyExclusive to allow switches to
+// turn fully off before the complementary switch is turned on.
+func DeadTime(period time.Duration, lines ...int) DeadTimeConstraint {
+	return DeadTimeConstraint{Period: period, Lines: append([]int(nil), lines...)}
+}
+
+func (c DeadTimeConstraint) String() string {
+	return fmt.Sprintf("dead time %s between lines %v", c.Period, c.Lines)
+}
+
+func (c DeadTimeConstraint) lines() []int {
+	return c.Lines
+}
+
+func (c DeadTimeConstraint) check(t *transition) error {
+	e := ErrConstraintViolation{Constraint: c}
+	for _, o := range c.Lines {
+		if !t.changes(o) || t.value(o) == 0 {
+			continue
+		}
+		for _, x := range c.Lines {
+			if x == o || t.value(x) != 0 {
+				continue
+			}
+			wait := c.Period
+			if !t.changes(x) {
+				elapsed, ok := t.since(x)
+				if !ok || elapsed >= c.Period {
+					continue
+				}
+				wait -= elapsed
+			}
+			e.add(o, wait)
+			e.add(x, wait)
+		}
+	}
+	return e.err()
+}
+
+// MinToggleIntervalConstraint limits the rate at which each of a set of lines
+// may change value.
+type MinToggleIntervalConstraint struct {
+	Period time.Duration
+	Lines  []int
+}
+
+// MinToggleInterval requires each of the lines to hold its value for at least
+// the period before being changed, limiting the maximum toggle rate of the
+// lines, e.g. to protect relays.
+func MinToggleInterval(period time.Duration, lines ...int) MinToggleIntervalConstraint {
+	return MinToggleIntervalConstraint{Period: period, Lines: append([]int(nil), lines...)}
+}
+
+func (c MinToggleIntervalConstraint) String() string {
+	return fmt.Sprintf("minimum toggle interval %s for lines %v", c.Period, c.Lines)
+}
+
+func (c MinToggleIntervalConstraint) lines() []int {
+	return c.Lines
+}
+
+func (c MinToggleIntervalConstraint) check(t *transition) error {
+	e := ErrConstraintViolation{Constraint: c}
+	for _, o := range c.Lines {
+		if !t.changes(o) {
+			continue
+		}
+		if elapsed, ok := t.since(o); ok && elapsed < c.Period {
+			e.add(o, c.Period-elapsed)
+		}
+	}
+	return e.err()
+}
+
+// add adds the line to the violation, extending the retry time if necessary.
+func (e *ErrConstraintViolation) add(line int, wait time.Duration) {
+	found := false
+	for _, l := range e.Lines {
+		if l == line {
+			found = true
+			break
+		}
+	}
+	if !found {
+		e.Lines = append(e.Lines, line)
+	}
+	if wait > e.RetryAfter {
+		e.RetryAfter = wait
+	}
+}
+
+// err returns the violation, or nil if no lines violate the constraint.
+func (e ErrConstraintViolation) err() error {
+	if len(e.Lines) == 0 {
+		return nil
+	}
+	return e
+}
+
+// constraints tracks the state of the lines required to check a set of
+// constraints.
+//
+// Lines are identified by offset in line requests and by position in Groups.
+type constraints struct {
+	cc []Constraint
+
+	// the most recently set value of each line.
+	values map[int]int
+
+	// the time each line last changed value.
+	changed map[int]time.Time
+}
+
+// newConstraints creates the constraints for the lines, which have the
+// given initial values.
+//
+// Returns ErrInvalidOffset if a constraint refers to a line not in lines.
+func newConstraints(cc []Constraint, lines []int, values map[int]int) (*constraints, error) {
+	if len(cc) == 0 {
+		return nil, nil
+	}
+	requested := map[int]bool{}
+	for _, o := range lines {
+		requested[o] = true
+	}
+	for _, c := range cc {
+		for _, o := range c.lines() {
+			if !requested[o] {
+				return nil, ErrInvalidOffset
+			}
+		}
+	}
+	cs := &constraints{
+		cc:      cc,
+		values:  map[int]int{},
+		changed: map[int]time.Time{},
+	}
+	for o, v := range values {
+		cs.values[o] = active(v)
+	}
+	return cs, nil
+}
+
+// check returns an ErrConstraintViolation for the first constraint violated
+// by setting the lines to the next values.
+func (cs *constraints) check(next map[int]int) error {
+	if cs == nil {
+		return nil
+	}
+	t := transition{cs: cs, next: next, now: time.Now()}
+	for _, c := range cs.cc {
+		if err := c.check(&t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOptions returns an ErrConstraintViolation for the first constraint
+// violated by applying the options, such as a release state, to the
+// configuration.
+func (cs *constraints) checkOptions(lco lineConfigOptions, options []LineConfigOption) error {
+	if cs == nil || len(options) == 0 {
+		return nil
+	}
+	// a copy, as the options alter the config in place.
+	lco = lco.subset(lco.offsets, lco.offsets)
+	for _, option := range options {
+		option.applyLineConfigOption(&lco)
+	}
+	return cs.check(lco.drivenValues())
+}
+
+// record records the values the lines have been set to.
+func (cs *constraints) record(values map[int]int) {
+	if cs == nil {
+		return
+	}
+	now := time.Now()
+	for o, v := range values {
+		if v = active(v); cs.values[o] != v {
+			cs.values[o] = v
+			cs.changed[o] = now
+		}
+	}
+}
+
+// transition is a proposed change to the values of lines.
+type transition struct {
+	cs   *constraints
+	next map[int]int
+	now  time.Time
+}
+
+// value returns the value of the line after the transition.
+func (t *transition) value(line int) int {
+	if v, ok := t.next[line]; ok {
+		return v
+	}
+	return t.cs.values[line]
+}
+
+// changes returns true if the transition changes the value of the line.
+func (t *transition) changes(line int) bool {
+	v, ok := t.next[line]
+	return ok && active(v) != t.cs.values[line]
+}
+
+// since returns the time since the line last changed value, and false if the
+// line has not changed value.
+func (t *transition) since(line int) (time.Duration, bool) {
+	changed, ok := t.cs.changed[line]
+	if !ok {
+		return 0, false
+	}
+	return t.now.Sub(changed), true
+}
+
+// active returns the value normalised to 1 if active, else 0.
+func active(v int) int {
+	if v != 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,233 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// debouncer debounces edge events in userspace, for lines where the kernel
+// does not support debouncing.
+//
+// The kernel is requested to report both edges on the debounced lines, and an
+// edge is only passed to the event handler once the line has been stable for
+// the debounce period.
+type debouncer struct {
+	eh EventHandler
+
+	// mu covers all that follow, and serializes calls to eh.
+	mu        sync.Mutex
+	lines     map[int]*debounceLine
+	seqno     uint32
+	lineSeqno map[int]uint32
+	closed    bool
+}
+
+// debounceLine is the debounce state of a single line.
+type debounceLine struct {
+	period time.Duration
+	edges  LineEdge
+
+	// the last reported active state, or -1 if not yet known.
+	state int
+
+	// the most recent edge, which is reported once the line has settled.
+	last  LineEvent
+	timer *time.Timer
+
+	// identifies the timer for the most recent edge, so superseded timers
+	// are ignored.
+	gen int
+}
+
+// newDebouncer creates a debouncer that debounces the lines in lco that
+// require it, and passes the debounced events to eh.
+func newDebouncer(lco lineConfigOptions, eh EventHandler) *debouncer {
+	d := &debouncer{
+		eh:        eh,
+		lines:     map[int]*debounceLine{},
+		lineSeqno: map[int]uint32{},
+	}
+	d.configure(lco)
+	return d
+}
+
+// configure updates the set of debounced lines, and their debounce periods
+// and edges, retaining the state of lines that remain debounced.
+func (d *debouncer) configure(lco lineConfigOptions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lines := map[int]*debounceLine{}
+	for _, offset := range lco.offsets {
+		lc := lco.config(offset)
+		if !lc.isDebouncedEdges() {
+			continue
+		}
+		dl := d.lines[offset]
+		if dl == nil {
+			dl = &debounceLine{state: -1}
+		}
+		dl.period = lc.DebouncePeriod
+		dl.edges = lc.EdgeDetection
+		lines[offset] = dl
+	}
+	for offset, dl := range d.lines {
+		if lines[offset] == nil && dl.timer != nil {
+			dl.timer.Stop()
+		}
+	}
+	d.lines = lines
+}
+
+// config returns the debounce config of the line, and true if the line is
+// being debounced.
+func (d *debouncer) config(offset int) (time.Duration, LineEdge, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dl := d.lines[offset]
+	if dl == nil {
+		return 0, LineEdgeNone, false
+	}
+	return dl.period, dl.edges, true
+}
+
+// handle is the EventHandler for the raw events from the kernel.
+func (d *debouncer) handle(evt LineEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	dl := d.lines[evt.Offset]
+	if dl == nil {
+		d.emit(evt)
+		return
+	}
+	if dl.state < 0 {
+		// the line was in the opposite state prior to the first edge
+		dl.state = 1
+		if evt.Type == LineEventRisingEdge {
+			dl.state = 0
+		}
+	}
+	dl.last = evt
+	dl.gen++
+	if dl.timer != nil {
+		dl.timer.Stop()
+	}
+	gen := dl.gen
+	dl.timer = time.AfterFunc(dl.period, func() {
+		d.settle(evt.Offset, gen)
+	})
+}
+
+// settle reports the most recent edge on the line, if the line has settled
+// in a different state to the one last reported.
+func (d *debouncer) settle(offset int, gen int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dl := d.lines[offset]
+	if d.closed || dl == nil || dl.gen != gen {
+		return
+	}
+	state := 0
+	edge := LineEdgeFalling
+	if dl.last.Type == LineEventRisingEdge {
+		state = 1
+		edge = LineEdgeRising
+	}
+	if state == dl.state {
+		return
+	}
+	dl.state = state
+	if dl.edges&edge == 0 {
+		return
+	}
+	d.emit(dl.last)
+}
+
+// emit passes the event to the event handler, renumbering uAPI v2 events so
+// the seqnos only cover the reported events.
+//
+// The caller must hold the lock.
+func (d *debouncer) emit(evt LineEvent) {
+	if evt.Seqno != 0 {
+		d.seqno++
+		d.lineSeqno[evt.Offset]++
+		evt.Seqno = d.seqno
+		evt.LineSeqno = d.lineSeqno[evt.Offset]
+	}
+	d.eh(evt)
+}
+
+// Close stops the debouncer, discarding any pending edges.
+func (d *debouncer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	for _, dl := range d.lines {
+		if dl.timer != nil {
+			dl.timer.Stop()
+		}
+	}
+	return nil
+}
+
+// isDebouncedEdges returns true if the line is a debounced input with edge
+// detection, and so can be debounced in userspace.
+func (lc LineConfig) isDebouncedEdges() bool {
+	return lc.Debounced &&
+		lc.Direction == LineDirectionInput &&
+		lc.EdgeDetection != LineEdgeNone
+}
+
+// hasDebouncedEdges returns true if any of the lines can be debounced in
+// userspace.
+func (lco lineConfigOptions) hasDebouncedEdges() bool {
+	for _, offset := range lco.offsets {
+		if lco.config(offset).isDebouncedEdges() {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutDebounce returns a copy of the config with the debounce of the lines
+// that are debounced in userspace removed, and with both edges enabled on
+// those lines so the debouncer can track their state.
+func (lco lineConfigOptions) withoutDebounce() lineConfigOptions {
+	strip := func(lc LineConfig) LineConfig {
+		if lc.isDebouncedEdges() {
+			lc.Debounced = false
+			lc.DebouncePeriod = 0
+			lc.EdgeDetection = LineEdgeBoth
+		}
+		return lc
+	}
+	lco.defCfg = strip(lco.defCfg)
+	if lco.lineCfg != nil {
+		lineCfg := make(map[int]*LineConfig, len(lco.lineCfg))
+		for offset, plc := range lco.lineCfg {
+			lc := strip(*plc)
+			lineCfg[offset] = &lc
+		}
+		lco.lineCfg = lineCfg
+	}
+	return lco
+}
+
+// isDebounceUnsupported returns true if the error from a line request may
+// indicate that the driver does not support debouncing the lines.
+func isDebounceUnsupported(err error) bool {
+	switch err {
+	case unix.EINVAL, unix.EIO, unix.ENXIO, unix.EOPNOTSUPP, unix.Errno(524):
+		// 524 is the kernel internal ENOTSUPP, which can leak to userspace.
+		return true
+	}
+	return false
+}
@@ -74,7 +74,7 @@ func main() {
 	}
 
 	// period := 10 * time.Millisecond
-	hit, err := gpiod.RequestLine("gpiochip0", hitpin, gpiod.WithPullUp, gpiod.WithRisingEdge, gpiod.WithEventHandler(eh))
+	hit, err := gpiod.RequestLine("gpiochip0", hitpin, gpiod.WithPullUp, gpiod.WithRisingEdge, gpiod.WithEventHandler(eh), gpiod.WithEventQueue(4, gpiod.EventQueueDropNewest))
 	if err != nil {
 		fmt.Printf("RequestLine returned error: %s\n", err)
 		if err == syscall.Errno(22) {
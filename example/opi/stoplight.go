@@ -39,7 +39,8 @@ func main() {
 	values := map[int]string{0: "inactive", 1: "active"}
 	offset := orangepi.GPIO13
 	v := 0
-	l, err := gpiod.RequestLine("gpiochip0", offset, gpiod.AsOutput(v))
+	// revert line to input on the way out, including on panic
+	l, err := gpiod.RequestLine("gpiochip0", offset, gpiod.AsOutput(v), gpiod.WithReleaseState(gpiod.AsInput))
 	if err != nil {
 		fmt.Printf("RequestLine returned error: %s\n", err)
 		if err == syscall.Errno(22) {
@@ -48,26 +49,28 @@ func main() {
 		os.Exit(1)
 	}
 
-	// revert line to input on the way out
-	defer func() {
-		l.Reconfigure(gpiod.AsInput)
-		l.Close()
-	}()
+	defer l.Close()
 
+	// the signals are handled here, to end the loop and let the deferred
+	// Close apply the release state, rather than by gpiod.ReleaseOnSignal,
+	// which would terminate the process as soon as the release state is
+	// applied.  ReleaseOnPanic covers the loop panicking.
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	defer signal.Stop(quit)
 
-	for {
-		select {
-		case <-time.After(2 * time.Second):
-			v ^= 1
-			l.SetValue(v)
-			fmt.Printf("Set pin %d %s\n", offset, values[v])
-		case <-quit:
-			fmt.Printf("stopping...")
-			return
+	gpiod.ReleaseOnPanic(func() {
+		for {
+			select {
+			case <-time.After(2 * time.Second):
+				v ^= 1
+				l.SetValue(v)
+				fmt.Printf("Set pin %d %s\n", offset, values[v])
+			case <-quit:
+				fmt.Printf("stopping...")
+				return
+			}
 		}
-	}
+	})
 }
 
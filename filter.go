@@ -0,0 +1,291 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"sync"
+	"time"
+)
+
+// eventFilter filters the edge events passed to the event handler, as per
+// the event filters in the line config.
+//
+// Each line passes its events through the following stages, each of which is
+// disabled if not configured:
+//   - a pair stage that holds each edge for the pulse width or pair window, and
+//     drops the edge along with any opposite edge that arrives in that time.
+//   - a rate stage that drops events exceeding the maximum event rate.
+//
+// The held edges are compared using their timestamps, so the filtering is
+// not affected by the latency of reading the events.
+//
+// Events are passed on in the order they are received, so while an edge is
+// held the subsequent events from all lines are also held, until the held
+// edge is either passed on or dropped.
+type eventFilter struct {
+	eh EventHandler
+
+	// mu covers all that follow, and serializes calls to eh.
+	mu    sync.Mutex
+	lines map[int]*filterLine
+
+	// the events not yet passed on, in the order they were received.
+	pending []*pendingEvent
+
+	// the seqnos of the last event passed to eh.
+	seqno     uint32
+	lineSeqno map[int]uint32
+
+	// set once an event has been filtered, after which the passed events are
+	// renumbered so the seqnos remain contiguous.
+	renumber bool
+
+	filtered uint64
+	closed   bool
+}
+
+// filterLine is the filter config and state of a single line.
+type filterLine struct {
+	minPulseWidth  time.Duration
+	edgePairWindow time.Duration
+	maxEventRate   int
+
+	// the edge held by the pair stage.
+	held  *pendingEvent
+	timer *time.Timer
+	gen   int
+
+	// the timestamps of the events recently passed by the rate stage.
+	recent []time.Duration
+}
+
+// pendingEvent is an event that has passed, or is held by, the pair stage,
+// but is yet to be passed on.
+type pendingEvent struct {
+	evt LineEvent
+
+	// the line, if filtered.
+	fl *filterLine
+
+	// true while held by the pair stage.
+	held bool
+}
+
+// newEventFilter creates an eventFilter that filters the events from the
+// lines in lco, and passes the remaining events to eh.
+func newEventFilter(lco lineConfigOptions, eh EventHandler) *eventFilter {
+	f := &eventFilter{
+		eh:        eh,
+		lines:     map[int]*filterLine{},
+		lineSeqno: map[int]uint32{},
+	}
+	f.configure(lco)
+	return f
+}
+
+// configure updates the filters of the lines.
+//
+// Any edge held by a line whose filters are removed is passed on.
+func (f *eventFilter) configure(lco lineConfigOptions) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lines := map[int]*filterLine{}
+	for _, offset := range lco.offsets {
+		lc := lco.config(offset)
+		if !lc.isFiltered() {
+			continue
+		}
+		fl := f.lines[offset]
+		if fl == nil {
+			fl = &filterLine{}
+		}
+		fl.minPulseWidth = lc.MinPulseWidth
+		fl.edgePairWindow = lc.EdgePairWindow
+		fl.maxEventRate = lc.MaxEventRate
+		lines[offset] = fl
+	}
+	old := f.lines
+	f.lines = lines
+	for offset, fl := range old {
+		if lines[offset] == nil {
+			f.release(fl)
+		}
+	}
+	f.flush()
+}
+
+// config returns the filter config of the line.
+func (f *eventFilter) config(offset int) (minPulseWidth, edgePairWindow time.Duration, maxEventRate int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if fl := f.lines[offset]; fl != nil {
+		return fl.minPulseWidth, fl.edgePairWindow, fl.maxEventRate
+	}
+	return 0, 0, 0
+}
+
+// handle is the EventHandler for the unfiltered events.
+func (f *eventFilter) handle(evt LineEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	fl := f.lines[evt.Offset]
+	if fl == nil {
+		f.pending = append(f.pending, &pendingEvent{evt: evt})
+		f.flush()
+		return
+	}
+	if fl.held != nil {
+		held := fl.held.evt
+		if held.Type != evt.Type && evt.Timestamp-held.Timestamp < fl.window(held) {
+			// a glitch - drop both the held edge and this one
+			f.drop(fl)
+			f.filtered++
+			f.flush()
+			return
+		}
+		f.release(fl)
+	}
+	pe := &pendingEvent{evt: evt, fl: fl}
+	f.pending = append(f.pending, pe)
+	window := fl.window(evt)
+	if window <= 0 {
+		f.flush()
+		return
+	}
+	pe.held = true
+	fl.held = pe
+	fl.gen++
+	gen := fl.gen
+	fl.timer = time.AfterFunc(window, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if !f.closed && fl.gen == gen {
+			f.release(fl)
+			f.flush()
+		}
+	})
+}
+
+// window returns the time the pair stage holds the edge.
+func (fl *filterLine) window(evt LineEvent) time.Duration {
+	if evt.Type == LineEventRisingEdge && fl.minPulseWidth > fl.edgePairWindow {
+		return fl.minPulseWidth
+	}
+	return fl.edgePairWindow
+}
+
+// drop discards the edge held by the line.
+//
+// The caller must hold the lock.
+func (f *eventFilter) drop(fl *filterLine) {
+	if fl.timer != nil {
+		fl.timer.Stop()
+	}
+	for i, pe := range f.pending {
+		if pe == fl.held {
+			f.pending = append(f.pending[:i], f.pending[i+1:]...)
+			break
+		}
+	}
+	fl.held = nil
+	fl.gen++
+	f.filtered++
+	f.renumber = true
+}
+
+// release stops holding the edge held by the line, if any, so it may be
+// passed on by flush.
+//
+// The caller must hold the lock.
+func (f *eventFilter) release(fl *filterLine) {
+	if fl.held == nil {
+		return
+	}
+	if fl.timer != nil {
+		fl.timer.Stop()
+	}
+	fl.held.held = false
+	fl.held = nil
+	fl.gen++
+}
+
+// flush passes on the pending events received before the first held edge.
+//
+// The caller must hold the lock.
+func (f *eventFilter) flush() {
+	for len(f.pending) > 0 && !f.pending[0].held {
+		pe := f.pending[0]
+		f.pending[0] = nil
+		f.pending = f.pending[1:]
+		if pe.fl == nil {
+			f.emit(pe.evt)
+			continue
+		}
+		f.limit(pe.fl, pe.evt)
+	}
+}
+
+// limit passes the event on, unless the line has exceeded its maximum event
+// rate.
+//
+// The caller must hold the lock.
+func (f *eventFilter) limit(fl *filterLine, evt LineEvent) {
+	if fl.maxEventRate > 0 {
+		if len(fl.recent) >= fl.maxEventRate {
+			if evt.Timestamp-fl.recent[0] < time.Second {
+				f.filtered++
+				f.renumber = true
+				return
+			}
+			fl.recent = fl.recent[1:]
+		}
+		fl.recent = append(fl.recent, evt.Timestamp)
+	}
+	f.emit(evt)
+}
+
+// emit passes the event to the event handler.
+//
+// The caller must hold the lock.
+func (f *eventFilter) emit(evt LineEvent) {
+	if evt.Seqno != 0 {
+		if f.renumber {
+			evt.Seqno = f.seqno + 1
+			evt.LineSeqno = f.lineSeqno[evt.Offset] + 1
+		}
+		f.seqno = evt.Seqno
+		f.lineSeqno[evt.Offset] = evt.LineSeqno
+	}
+	f.eh(evt)
+}
+
+// stats returns the number of events filtered.
+func (f *eventFilter) stats() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.filtered
+}
+
+// Close stops the filter, discarding any held edges.
+func (f *eventFilter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for _, fl := range f.lines {
+		if fl.timer != nil {
+			fl.timer.Stop()
+		}
+	}
+	f.pending = nil
+	return nil
+}
+
+// isFiltered returns true if any event filters are enabled on the line.
+func (lc LineConfig) isFiltered() bool {
+	return lc.MinPulseWidth > 0 || lc.EdgePairWindow > 0 || lc.MaxEventRate > 0
+}
@@ -39,6 +39,8 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -98,8 +100,22 @@ type LineConfig struct {
 	// The line debounce period.
 	DebouncePeriod time.Duration
 
+	// A flag indicating the line is debounced in userspace, rather than by
+	// the kernel.
+	DebounceEmulated bool
+
 	// The source clock for events on the line.
 	EventClock LineEventClock
+
+	// The minimum width of an active pulse, from rising to falling edge,
+	// for its edges to be reported.
+	MinPulseWidth time.Duration
+
+	// The window within which a pair of opposite edges is suppressed.
+	EdgePairWindow time.Duration
+
+	// The maximum number of edge events reported per second.
+	MaxEventRate int
 }
 
 // LineDirection indicates the direction of a line.
@@ -174,6 +190,10 @@ const (
 
 	// LineEventClockRealtime indicates the source clock is CLOCK_REALTIME.
 	LineEventClockRealtime
+
+	// LineEventClockHTE indicates the source clock is the hardware timestamp
+	// engine (HTE).
+	LineEventClockHTE
 )
 
 // LineInfo contains a summary of publicly available information about the
@@ -230,8 +250,71 @@ func RequestLines(chip string, offsets []int, options ...LineReqOption) (*Lines,
 	return c.RequestLines(offsets, options...)
 }
 
+// FindLine finds the line with the given name on the available GPIO chips.
+//
+// Returns the name of the chip containing the line and the offset of the line
+// on that chip.
+//
+// Returns ErrLineNotFound if no line has the name, or ErrLineNameAmbiguous
+// if more than one line has the name.
+func FindLine(name string) (chip string, offset int, err error) {
+	ll, err := findLines(Chips(), []string{name})
+	if err != nil {
+		return "", 0, err
+	}
+	return ll[0].chip, ll[0].offset, nil
+}
+
+// RequestLinesByName requests control of a collection of lines, identified
+// by name, from the available GPIO chips.
+//
+// The lines must all be on the same chip.
+//
+// If granted, control is maintained until the Lines are closed.
+func RequestLinesByName(names []string, options ...LineReqOption) (*Lines, error) {
+	if len(names) == 0 {
+		// as the kernel would for an empty request
+		return nil, unix.EINVAL
+	}
+	ll, err := findLines(Chips(), names)
+	if err != nil {
+		return nil, err
+	}
+	chip := ll[0].chip
+	offsets := make([]int, len(ll))
+	for i, l := range ll {
+		if l.chip != chip {
+			return nil, ErrMultipleChips
+		}
+		offsets[i] = l.offset
+	}
+	return RequestLines(chip, offsets, options...)
+}
+
 // NewChip opens a GPIO character device.
+//
+// The chip may be identified by name, e.g. gpiochip0, by number, by the path
+// to its device file, or by any of the other forms accepted by
+// ParseChipSelector, such as its label.
+//
+// A name that ParseChipSelector takes to be a label is first tried as a
+// device file in /dev, such as a udev symlink, and is only matched against
+// the chip labels if that is not a chip.  The "label:" prefix forces
+// matching by label.
 func NewChip(name string, options ...ChipOption) (*Chip, error) {
+	if sel := ParseChipSelector(name); len(sel.Name) != 0 {
+		// a chip number is mapped to its name.
+		name = sel.Name
+	} else {
+		// a bare name may be a device file in /dev, such as a udev symlink,
+		// which takes precedence over a label.
+		if sel.Label != name || IsChip(nameToPath(name)) != nil {
+			var err error
+			if name, err = FindChip(sel); err != nil {
+				return nil, err
+			}
+		}
+	}
 	path := nameToPath(name)
 	err := IsChip(path)
 	if err != nil {
@@ -379,6 +462,12 @@ func lineInfoV2ToLineConfig(li uapi.LineInfoV2) LineConfig {
 		lc.Bias = LineBiasDisabled
 	}
 
+	if li.Flags.HasRealtimeEventClock() {
+		lc.EventClock = LineEventClockRealtime
+	} else if li.Flags.HasHTEEventClock() {
+		lc.EventClock = LineEventClockHTE
+	}
+
 	for i := 0; i < int(li.NumAttrs); i++ {
 		if li.Attrs[i].ID == uapi.LineAttributeIDDebounce {
 			lc.Debounced = true
@@ -416,39 +505,169 @@ func (c *Chip) Lines() int {
 // RequestLine requests control of a single line on the chip.
 //
 // If granted, control is maintained until the Line is closed.
+//
+// Returns an ErrLineBusy if the line is held by another request.
 func (c *Chip) RequestLine(offset int, options ...LineReqOption) (*Line, error) {
-	ll, err := c.RequestLines([]int{offset}, options...)
+	l := Line{}
+	err := c.requestLines(&l.baseLine, []int{offset}, options...)
 	if err != nil {
 		return nil, err
 	}
-	l := Line{
-		baseLine: baseLine{
-			offsets: ll.offsets,
-			values:  ll.values,
-			vfd:     ll.vfd,
-			isEvent: ll.isEvent,
-			chip:    ll.chip,
-			abi:     ll.abi,
-			defCfg:  ll.defCfg,
-			watcher: ll.watcher,
-		},
-	}
 	return &l, nil
 }
 
 // RequestLines requests control of a collection of lines on the chip.
 //
 // If granted, control is maintained until the Lines are closed.
+//
+// Returns an ErrLineBusy if any of the lines are held by another request.
 func (c *Chip) RequestLines(offsets []int, options ...LineReqOption) (*Lines, error) {
+	ll := Lines{}
+	err := c.requestLines(&ll.baseLine, offsets, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &ll, nil
+}
+
+// FindLine finds the line with the given name on the chip.
+//
+// Returns ErrLineNotFound if no line on the chip has the name, or
+// ErrLineNameAmbiguous if more than one line on the chip has the name.
+func (c *Chip) FindLine(name string) (int, error) {
+	ll, err := c.findLines([]string{name})
+	if err != nil {
+		return 0, err
+	}
+	return ll[0].offset, nil
+}
+
+// RequestLinesByName requests control of a collection of lines on the chip,
+// identified by name.
+//
+// If granted, control is maintained until the Lines are closed.
+func (c *Chip) RequestLinesByName(names []string, options ...LineReqOption) (*Lines, error) {
+	ll, err := c.findLines(names)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make([]int, len(ll))
+	for i, l := range ll {
+		offsets[i] = l.offset
+	}
+	return c.RequestLines(offsets, options...)
+}
+
+// lineLocation identifies a line by chip and offset.
+type lineLocation struct {
+	chip   string
+	offset int
+}
+
+// lineFinder finds the lines matching a set of names.
+type lineFinder map[string][]lineLocation
+
+func newLineFinder(names []string) (lineFinder, error) {
+	lf := make(lineFinder, len(names))
+	for _, name := range names {
+		if _, ok := lf[name]; ok {
+			return nil, ErrDuplicateLineName{name}
+		}
+		lf[name] = nil
+	}
+	return lf, nil
+}
+
+// scan adds any lines on the chip with names being searched for.
+func (lf lineFinder) scan(c *Chip) error {
+	for o := 0; o < c.lines; o++ {
+		li, err := c.LineInfo(o)
+		if err != nil {
+			return err
+		}
+		if len(li.Name) == 0 {
+			continue
+		}
+		if ll, ok := lf[li.Name]; ok {
+			lf[li.Name] = append(ll, lineLocation{c.Name, o})
+		}
+	}
+	return nil
+}
+
+// locations returns the location of each of the named lines, in the same
+// order as the names.
+func (lf lineFinder) locations(names []string) ([]lineLocation, error) {
+	ll := make([]lineLocation, len(names))
+	for i, name := range names {
+		found := lf[name]
+		switch len(found) {
+		case 0:
+			return nil, ErrLineNotFound{name}
+		case 1:
+			ll[i] = found[0]
+		default:
+			e := ErrLineNameAmbiguous{Name: name}
+			for _, l := range found {
+				e.Chips = append(e.Chips, l.chip)
+				e.Offsets = append(e.Offsets, l.offset)
+			}
+			return nil, e
+		}
+	}
+	return ll, nil
+}
+
+func (c *Chip) findLines(names []string) ([]lineLocation, error) {
+	lf, err := newLineFinder(names)
+	if err != nil {
+		return nil, err
+	}
+	if err = lf.scan(c); err != nil {
+		return nil, err
+	}
+	return lf.locations(names)
+}
+
+// findLines finds the named lines on the named chips.
+//
+// Chips that cannot be opened are ignored.
+func findLines(chips []string, names []string) ([]lineLocation, error) {
+	lf, err := newLineFinder(names)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range chips {
+		c, err := NewChip(name)
+		if err != nil {
+			continue
+		}
+		err = lf.scan(c)
+		c.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return lf.locations(names)
+}
+
+// requestLines performs the request common to Line and Lines, populating the
+// provided baseLine.
+func (c *Chip) requestLines(l *baseLine, offsets []int, options ...LineReqOption) error {
 	for _, o := range offsets {
 		if o < 0 || o >= c.lines {
-			return nil, ErrInvalidOffset
+			return ErrInvalidOffset
 		}
 	}
-	offsets = append([]int(nil), offsets...)
+	return c.requestWithOptions(l, c.newLineReqOptions(offsets, options))
+}
+
+// newLineReqOptions returns the request options for the lines, being the chip
+// defaults updated by the options.
+func (c *Chip) newLineReqOptions(offsets []int, options []LineReqOption) lineReqOptions {
 	lro := lineReqOptions{
 		lineConfigOptions: lineConfigOptions{
-			offsets: offsets,
+			offsets: append([]int(nil), offsets...),
 			values:  map[int]int{},
 			defCfg:  c.options.config,
 		},
@@ -459,34 +678,181 @@ func (c *Chip) RequestLines(offsets []int, options ...LineReqOption) (*Lines, er
 	for _, option := range options {
 		option.applyLineReqOption(&lro)
 	}
-	ll := Lines{
-		baseLine: baseLine{
-			offsets: offsets,
-			values:  lro.values,
-			chip:    c.Name,
-			abi:     lro.abi,
-			defCfg:  lro.defCfg,
-		},
+	return lro
+}
+
+// requestWithOptions performs the request described by the options,
+// populating the provided baseLine.
+func (c *Chip) requestWithOptions(l *baseLine, lro lineReqOptions) error {
+	cs, err := newConstraints(lro.constraints, lro.offsets, lro.drivenValues())
+	if err != nil {
+		return err
+	}
+	if err := cs.checkOptions(lro.lineConfigOptions, lro.releaseState); err != nil {
+		return err
+	}
+	if err := c.requestOrWait(l, lro); err != nil {
+		return err
+	}
+	l.constraints = cs
+	if len(lro.releaseState) != 0 {
+		l.releaseState = lro.releaseState
+		registerReleaser(l)
+	}
+	return nil
+}
+
+// request performs the kernel request for the lines described by the
+// options, populating the provided baseLine.
+func (c *Chip) request(l *baseLine, lro lineReqOptions) error {
+	if lro.shard {
+		if shards := lro.shards(); shards != nil {
+			return c.requestShards(l, lro, shards)
+		}
+	}
+	if len(lro.offsets) > uapi.LinesMax {
+		// as the kernel would
+		return unix.EINVAL
+	}
+	l.offsets = lro.offsets
+	l.values = lro.values
+	l.chip = c.Name
+	l.abi = lro.abi
+	l.defCfg = lro.defCfg
+	var ec *eventChannel
+	if lro.ech != nil {
+		ec = newEventChannel(lro.ech.ctx, lro.ech.ch)
+		lro.eh = ec.handle
+	}
+	if lro.geh != nil {
+		lro.eh = lro.geh.forLines(c.Name, lro.offsets)
+	}
+	if lro.eh != nil || len(lro.handlers) != 0 {
+		l.dispatcher = newDispatcher(lro.eh, lro.handlers)
+		lro.eh = l.dispatcher.handle
+	}
+	if lro.eh != nil && (lro.queueSize > 0 || lro.workers > 0) {
+		l.queue = newEventQueue(lro.queueSize, lro.workers, lro.queuePolicy, lro.eh)
+		lro.eh = l.queue.handle
+		if l.dispatcher != nil {
+			// so waiters see any events dropped by the queue.
+			lro.eh = l.dispatcher.notifyBefore(lro.eh)
+		}
+	}
+	if lro.eh != nil && lro.initialState {
+		l.gate = newEventGate(lro.eh)
+		lro.eh = l.gate.handle
+	}
+	l.seq = newSeqTracker(lro.oh)
+	l.clocks = newEventClocks(lro.lineConfigOptions)
+	if lro.eh != nil {
+		l.filter = newEventFilter(lro.lineConfigOptions, lro.eh)
+		lro.eh = l.filter.handle
+	}
+	var err error
+	if lro.eh != nil && lro.hasDebouncedEdges() && (lro.softDebounce || lro.abi == 1) {
+		err = c.requestDebounced(l, lro)
+	} else {
+		err = c.requestKernelLines(l, lro)
+		if err != nil && lro.eh != nil && lro.hasDebouncedEdges() && isDebounceUnsupported(err) {
+			// fallback to debouncing in userspace
+			if c.requestDebounced(l, lro) == nil {
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		if l.queue != nil {
+			l.queue.Close()
+		}
+		return err
+	}
+	if ec != nil {
+		ec.start()
+		l.ech = ec
+	}
+	return l.openGate(lro.lineConfigOptions)
+}
+
+// openGate queues the initial state events for the newly requested lines for
+// the event handler, and then opens the gate to edge events.
+//
+// If the initial states cannot be read then the request is closed.
+func (l *baseLine) openGate(lco lineConfigOptions) error {
+	if l.gate == nil {
+		return nil
+	}
+	evts, err := l.initialStates(lco)
+	if err != nil {
+		l.Close()
+		return err
+	}
+	l.gate.open(evts)
+	return nil
+}
+
+// requestDebounced performs the request with the debouncing of edge events
+// performed in userspace rather than by the kernel.
+func (c *Chip) requestDebounced(l *baseLine, lro lineReqOptions) error {
+	db := newDebouncer(lro.lineConfigOptions, lro.eh)
+	lro.eh = db.handle
+	lro.lineConfigOptions = lro.withoutDebounce()
+	if err := c.requestKernelLines(l, lro); err != nil {
+		return err
+	}
+	l.debouncer = db
+	return nil
+}
+
+// requestKernelLines performs the kernel request for the lines, populating
+// the provided baseLine.
+func (c *Chip) requestKernelLines(l *baseLine, lro lineReqOptions) error {
+	if lro.eh != nil {
+		lro.eh = l.seq.wrap(lro.eh)
+	}
+	var poll *poller
+	if lro.pollPeriod > 0 && lro.eh != nil && lro.hasEdges() {
+		// the kernel request is made without edge detection, and the poller
+		// stands in for the watcher.
+		poll = newPoller(lro.abi, lro.pollPeriod, lro.lineConfigOptions, lro.eh)
+		lro.lineConfigOptions = lro.withoutEdges()
+		lro.eh = nil
+	}
+	if lro.eh != nil {
+		lro.eh = l.clocks.wrap(lro.eh)
 	}
 	var err error
-	if ll.abi == 2 {
-		ll.vfd, ll.watcher, err = c.getLine(ll.offsets, lro)
+	if l.abi == 2 {
+		l.vfd, l.watcher, err = c.getLine(l.offsets, lro)
 	} else {
 		err = lro.defCfg.v1Validate()
 		if err != nil {
-			return nil, err
+			return err
 		}
-		if lro.eh == nil {
-			ll.vfd, err = c.getHandleRequest(ll.offsets, lro)
+		if lro.imported {
+			if lro.eh != nil {
+				return ErrUapiIncompatibility{"edge detection on an imported request", 1}
+			}
+			l.vfd = uintptr(lro.fd)
+		} else if lro.eh == nil {
+			l.vfd, err = c.getHandleRequest(l.offsets, lro)
 		} else {
-			ll.isEvent = true
-			ll.vfd, ll.watcher, err = c.getEventRequest(ll.offsets, lro)
+			l.isEvent = true
+			l.vfd, l.watcher, err = c.getEventRequest(l.offsets, lro)
 		}
 	}
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &ll, nil
+	if poll != nil {
+		if err = poll.start(l.vfd); err != nil {
+			unix.Close(int(l.vfd))
+			return err
+		}
+		l.watcher = poll
+		l.poll = poll
+	}
+	return nil
 }
 
 // creates the iw and ich
@@ -564,27 +930,36 @@ func (c *Chip) UnwatchLineInfo(offset int) error {
 }
 
 func (c *Chip) getLine(offsets []int, lro lineReqOptions) (uintptr, io.Closer, error) {
-
-	config, err := lro.toULineConfig()
-	if err != nil {
-		return 0, nil, err
-	}
-	lr := uapi.LineRequest{
-		Lines:  uint32(len(offsets)),
-		Config: config,
-	}
-	copy(lr.Consumer[:len(lr.Consumer)-1], lro.consumer)
-	// copy(hr.Offsets[:], offsets) - with cast
-	for i, o := range offsets {
-		lr.Offsets[i] = uint32(o)
-	}
-	err = uapi.GetLine(c.f.Fd(), &lr)
-	if err != nil {
-		return 0, nil, err
+	lr := uapi.LineRequest{Fd: lro.fd}
+	if !lro.imported {
+		config, err := lro.toULineConfig()
+		if err != nil {
+			return 0, nil, err
+		}
+		lr = uapi.LineRequest{
+			Lines:           uint32(len(offsets)),
+			Config:          config,
+			EventBufferSize: uint32(lro.eventBufferSize),
+		}
+		copy(lr.Consumer[:len(lr.Consumer)-1], lro.consumer)
+		// copy(hr.Offsets[:], offsets) - with cast
+		for i, o := range offsets {
+			lr.Offsets[i] = uint32(o)
+		}
+		err = uapi.GetLine(c.f.Fd(), &lr)
+		if err != nil {
+			return 0, nil, lro.hteError(err)
+		}
 	}
+	var err error
 	var w io.Closer
 	if lro.eh != nil {
-		w, err = newWatcher(lr.Fd, lro.eh)
+		// drain up to a kernel buffer full of events per read.
+		size := lro.eventBufferSize
+		if size <= 0 {
+			size = 16 * len(offsets)
+		}
+		w, err = newWatcher(lr.Fd, size, lro.eh)
 		if err != nil {
 			unix.Close(int(lr.Fd))
 			return 0, nil, err
@@ -660,8 +1035,11 @@ func (lc LineConfig) toLineFlagV2() (flags uapi.LineFlagV2) {
 		if lc.EdgeDetection&LineEdgeFalling != 0 {
 			flags |= uapi.LineFlagV2EdgeFalling
 		}
-		if lc.EventClock == LineEventClockRealtime {
+		switch lc.EventClock {
+		case LineEventClockRealtime:
 			flags |= uapi.LineFlagV2EventClockRealtime
+		case LineEventClockHTE:
+			flags |= uapi.LineFlagV2EventClockHTE
 		}
 	}
 
@@ -690,6 +1068,24 @@ func (lc LineConfig) toLineAttributes() (attrs []uapi.LineAttribute) {
 	return
 }
 
+// hteError converts an error from a kernel request or reconfigure to an
+// ErrUapiIncompatibility if the HTE event clock is requested and the error
+// indicates the kernel or chip does not support HTE.
+func (lco lineConfigOptions) hteError(err error) error {
+	switch err {
+	case unix.EINVAL, unix.ENODEV, unix.EOPNOTSUPP, unix.Errno(524):
+		// 524 is the kernel internal ENOTSUPP, which can leak to userspace.
+	default:
+		return err
+	}
+	for _, offset := range lco.offsets {
+		if lco.config(offset).EventClock == LineEventClockHTE {
+			return ErrUapiIncompatibility{"HTE event clock", 2}
+		}
+	}
+	return err
+}
+
 func (lc LineConfig) v1Validate() error {
 	if lc.Debounced {
 		return ErrUapiIncompatibility{"debounce", 1}
@@ -712,6 +1108,9 @@ func (c *Chip) getEventRequest(offsets []int, lro lineReqOptions) (uintptr, io.C
 		copy(er.Consumer[:len(er.Consumer)-1], lro.consumer)
 		err := uapi.GetLineEvent(c.f.Fd(), &er)
 		if err != nil {
+			for fd := range fds {
+				unix.Close(fd)
+			}
 			return 0, nil, err
 		}
 		fd := uintptr(er.Fd)
@@ -769,6 +1168,30 @@ type baseLine struct {
 	info    []*LineInfo
 	closed  bool
 	watcher io.Closer
+	ech     *eventChannel
+	seq     *seqTracker
+	// the kernel requests making up a sharded request.
+	shards requestSet
+	// the software edge detector for polled edges.
+	poll *poller
+	// the userspace debouncer for lines the kernel cannot debounce.
+	debouncer *debouncer
+	// the event filters.
+	filter *eventFilter
+	// the event clocks of the lines.
+	clocks *eventClocks
+	// the dispatcher of events to per-line handlers.
+	dispatcher *dispatcher
+	// the queue of events for the event handler.
+	queue *eventQueue
+	// holds back events while initial state events are generated.
+	gate *eventGate
+	// the configuration applied when the lines are released.
+	releaseState []LineConfigOption
+	// the current lease on the values of the lines.
+	lease *Lease
+	// the constraints on the values of output lines.
+	constraints *constraints
 }
 
 // UapiAbiVersion returns the version of the GPIO uAPI the line is using.
@@ -786,20 +1209,70 @@ func (l *baseLine) Chip() string {
 // Note that this includes waiting for any running event handler to return.
 // As a consequence the Close must not be called from the context of the event
 // handler - the Close should be called from a different goroutine.
+//
+// If the events are being delivered to a channel, the channel is closed once
+// the event watcher has exited.
+//
+// If the lines were requested WithReleaseState then the release state is
+// applied before the lines are released, regardless of any constraints, and
+// any error applying it is returned, though the lines are released
+// regardless.
 func (l *baseLine) Close() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	if l.closed {
 		return ErrClosed
 	}
+	if l.lease != nil {
+		l.lease.end()
+	}
+	var err error
+	if l.releaseState != nil {
+		unregisterReleaser(l)
+		err = l.release()
+	}
+	l.close()
+	return err
+}
+
+// close marks the line closed and releases its resources, without applying
+// any release state.
+//
+// The caller must hold the lock.
+func (l *baseLine) close() {
 	l.closed = true
+	if l.ech != nil {
+		// unblock any pending send before waiting on the watcher
+		l.ech.stop()
+	}
+	if l.queue != nil {
+		// unblock any pending queuing of events
+		l.queue.stop()
+	}
 	if l.watcher != nil {
+		// the watcher owns, and closes, the line request fd(s)
 		l.watcher.Close()
-	}
-	if !l.isEvent { // isEvent => v1 => closed by watcher
+	} else {
 		unix.Close(int(l.vfd))
 	}
-	return nil
+	if l.debouncer != nil {
+		l.debouncer.Close()
+	}
+	if l.filter != nil {
+		l.filter.Close()
+	}
+	if l.gate != nil {
+		l.gate.Close()
+	}
+	if l.queue != nil {
+		l.queue.Close()
+	}
+	if l.dispatcher != nil {
+		l.dispatcher.close()
+	}
+	if l.ech != nil {
+		l.ech.close()
+	}
 }
 
 // Reconfigure updates the configuration of the requested line(s).
@@ -821,67 +1294,408 @@ func (l *baseLine) Reconfigure(options ...LineConfigOption) error {
 	if l.closed {
 		return ErrClosed
 	}
-	lro := lineReqOptions{
-		lineConfigOptions: lineConfigOptions{
-			offsets: l.offsets,
-			values:  l.values,
-			defCfg:  l.defCfg,
-			lineCfg: l.lineCfg,
-		},
-	}
-	for _, option := range options {
-		option.applyLineConfigOption(&lro.lineConfigOptions)
-	}
-	if l.abi == 1 {
-		err := lro.defCfg.v1Validate()
-		if err != nil {
-			return err
-		}
-		hc := uapi.HandleConfig{Flags: lro.defCfg.toHandleFlags()}
-		for idx, offset := range lro.offsets {
-			hc.DefaultValues[idx] = uint8(lro.values[offset])
-		}
-		err = uapi.SetLineConfig(l.vfd, &hc)
-		if err == nil {
-			l.defCfg = lro.defCfg
-		}
-		return err
-	}
-	config, err := lro.toULineConfig()
-	if err != nil {
-		return err
-	}
-	err = uapi.SetLineConfigV2(l.vfd, &config)
-	if err == nil {
-		l.defCfg = lro.defCfg
-		l.lineCfg = lro.lineCfg
-	}
-	return err
+	return l.reconfigureOptions(options)
 }
 
-// Line represents a single requested line.
-type Line struct {
-	baseLine
+// reconfigureOptions applies the options to the current configuration of the
+// requested lines.
+//
+// The caller must hold the lock.
+func (l *baseLine) reconfigureOptions(options []LineConfigOption) error {
+	if l.isEvent {
+		return unix.EINVAL
+	}
+	return l.reconfigure(l.configWith(options))
 }
 
-// Offset returns the offset of the line within the chip.
-func (l *Line) Offset() int {
-	return l.offsets[0]
+// configWith returns a copy of the current configuration of the requested
+// lines with the options applied.
+//
+// The copy allows the current config to be retained if the reconfigure fails.
+//
+// The caller must hold the lock.
+func (l *baseLine) configWith(options []LineConfigOption) lineConfigOptions {
+	lco := lineConfigOptions{
+		offsets: l.offsets,
+		values:  l.values,
+		defCfg:  l.defCfg,
+		lineCfg: l.lineCfg,
+	}.subset(l.offsets, l.offsets)
+	for _, option := range options {
+		option.applyLineConfigOption(&lco)
+	}
+	return lco
 }
 
-// Info returns the information about the line.
-func (l *Line) Info() (info LineInfo, err error) {
+// applyReleaseState applies the release state to the requested lines.
+func (l *baseLine) applyReleaseState() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	if l.closed {
-		err = ErrClosed
-		return
-	}
-	if l.info != nil {
-		info = *l.info[0]
-		return
+		return ErrClosed
 	}
-	c, err := NewChip(l.chip, WithABIVersion(l.abi))
+	return l.release()
+}
+
+// release applies the release state to the requested lines.
+//
+// The release state is the safe state for the lines, so it is applied
+// regardless of the constraints, which were checked against it when the lines
+// were requested.  The resulting values are still recorded for subsequent
+// checks.
+//
+// The caller must hold the lock.
+func (l *baseLine) release() error {
+	if l.isEvent {
+		return unix.EINVAL
+	}
+	return l.reconfigureUnchecked(l.configWith(l.releaseState))
+}
+
+// applyConfig applies the configuration, which must be keyed by offset, to
+// the requested lines.
+func (l *baseLine) applyConfig(lco lineConfigOptions) error {
+	if l.isEvent {
+		return unix.EINVAL
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	return l.reconfigure(lco)
+}
+
+// reconfigure applies the configuration to the requested lines.
+//
+// Returns an ErrConstraintViolation, without altering the lines, if the
+// values resulting from the configuration violate the constraints.
+//
+// The caller must hold the lock.
+func (l *baseLine) reconfigure(lco lineConfigOptions) error {
+	if err := l.constraints.check(lco.drivenValues()); err != nil {
+		return err
+	}
+	return l.reconfigureUnchecked(lco)
+}
+
+// reconfigureUnchecked applies the configuration to the requested lines
+// without checking the constraints.
+//
+// The caller must hold the lock.
+func (l *baseLine) reconfigureUnchecked(lco lineConfigOptions) error {
+	handlers := lco.handlers
+	if handlers != nil {
+		if l.dispatcher == nil {
+			return ErrNoEventHandler
+		}
+		// handlers are applied by the dispatcher, not the shards.
+		lco.handlers = nil
+	}
+	driven := lco.drivenValues()
+	if l.gate != nil {
+		l.gate.close()
+	}
+	err := l.reconfigureKernel(lco)
+	if err == nil {
+		l.constraints.record(driven)
+		if l.dispatcher != nil {
+			l.dispatcher.update(handlers)
+		}
+		if l.filter != nil {
+			l.filter.configure(lco)
+		}
+		if l.clocks != nil {
+			l.clocks.configure(lco)
+		}
+	}
+	if l.gate != nil {
+		var evts []LineEvent
+		if err == nil {
+			// the lines have been reconfigured, so the edges have started
+			// even if their initial states cannot be read.
+			evts, _ = l.initialStates(lco)
+		}
+		l.gate.open(evts)
+	}
+	return err
+}
+
+// reconfigureKernel applies the configuration to the kernel line request(s),
+// and to any emulation of the parts of the configuration the kernel does not
+// perform.
+//
+// The caller must hold the lock.
+func (l *baseLine) reconfigureKernel(lco lineConfigOptions) error {
+	if l.shards != nil {
+		// the shards are configured by position
+		positions := make([]int, len(l.offsets))
+		for i := range positions {
+			positions[i] = i
+		}
+		err := firstError(l.shards.reconfigure(lco.subset(l.offsets, positions)))
+		if err == nil {
+			l.values = lco.values
+			l.defCfg = lco.defCfg
+			l.lineCfg = lco.lineCfg
+		}
+		return err
+	}
+	if l.poll != nil || l.debouncer != nil {
+		// the kernel only performs the parts of the config that are not
+		// emulated.
+		klco := lco
+		if l.debouncer != nil {
+			klco = klco.withoutDebounce()
+		}
+		pcfg := klco
+		if l.poll != nil {
+			klco = klco.withoutEdges()
+		}
+		err := l.reconfigureLines(klco)
+		if err == nil {
+			if l.poll != nil {
+				l.poll.configure(pcfg)
+			}
+			if l.debouncer != nil {
+				l.debouncer.configure(lco)
+			}
+			l.defCfg = lco.defCfg
+			l.lineCfg = lco.lineCfg
+		}
+		return err
+	}
+	return l.reconfigureLines(lco)
+}
+
+// reconfigureLines applies the configuration to the kernel line request.
+//
+// The caller must hold the lock.
+func (l *baseLine) reconfigureLines(lco lineConfigOptions) error {
+	if l.abi == 1 {
+		err := lco.defCfg.v1Validate()
+		if err != nil {
+			return err
+		}
+		hc := uapi.HandleConfig{Flags: lco.defCfg.toHandleFlags()}
+		for idx, offset := range lco.offsets {
+			hc.DefaultValues[idx] = uint8(lco.values[offset])
+		}
+		err = uapi.SetLineConfig(l.vfd, &hc)
+		if err == nil {
+			l.values = lco.values
+			l.defCfg = lco.defCfg
+		}
+		return err
+	}
+	config, err := lco.toULineConfig()
+	if err != nil {
+		return err
+	}
+	err = uapi.SetLineConfigV2(l.vfd, &config)
+	err = lco.hteError(err)
+	if err == nil {
+		l.values = lco.values
+		l.defCfg = lco.defCfg
+		l.lineCfg = lco.lineCfg
+	}
+	return err
+}
+
+// Fd returns the file descriptor of the line request.
+//
+// This allows the request to be added to an external event loop, such as
+// epoll, to be notified when edge events are available to be read.
+//
+// The file descriptor remains owned by the line and must not be closed or
+// read by the caller while events are being delivered to an event handler
+// or channel.
+//
+// A sharded request has no single file descriptor, so ^uintptr(0) is
+// returned.
+func (l *baseLine) Fd() uintptr {
+	if l.shards != nil {
+		return ^uintptr(0)
+	}
+	return l.vfd
+}
+
+// checkEventReader checks that edge events can be read directly from the
+// line request.
+func (l *baseLine) checkEventReader() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	if l.abi == 1 {
+		return ErrUapiIncompatibility{"synchronous event reading", 1}
+	}
+	if l.shards != nil {
+		return ErrSharded
+	}
+	if l.watcher != nil {
+		return ErrEventWatcher
+	}
+	return nil
+}
+
+// WaitEvent waits until an edge event is available to be read from the line
+// request, or the timeout expires.
+//
+// Returns true if an event is available to be read.
+// A negative timeout waits indefinitely.
+//
+// Only valid for lines requested with edge detection but without an event
+// handler or channel.
+//
+// Requires uAPI v2.
+func (l *baseLine) WaitEvent(timeout time.Duration) (bool, error) {
+	if err := l.checkEventReader(); err != nil {
+		return false, err
+	}
+	var deadline time.Time
+	if timeout >= 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	pfds := []unix.PollFd{{Fd: int32(l.vfd), Events: unix.POLLIN}}
+	for {
+		ms := -1
+		if timeout >= 0 {
+			// round up so the timeout is never shortened
+			ms = int((time.Until(deadline) + time.Millisecond - 1) / time.Millisecond)
+			if ms < 0 {
+				ms = 0
+			}
+		}
+		n, err := unix.Poll(pfds, ms)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		return n > 0, nil
+	}
+}
+
+// ReadEvent reads a single edge event from the line request.
+//
+// This function blocks until an event is available, so it should either be
+// called when an event is known to be available, e.g. following a WaitEvent,
+// or when blocking is acceptable.
+//
+// Note that a blocked ReadEvent is not unblocked by Close, so Close should
+// only be called when no ReadEvent is pending.
+//
+// Only valid for lines requested with edge detection but without an event
+// handler or channel.
+//
+// Requires uAPI v2.
+func (l *baseLine) ReadEvent() (LineEvent, error) {
+	if err := l.checkEventReader(); err != nil {
+		return LineEvent{}, err
+	}
+	uevt, err := uapi.ReadLineEvent(l.vfd)
+	if err != nil {
+		return LineEvent{}, err
+	}
+	evt := newLineEvent(uevt)
+	l.clocks.stamp(&evt)
+	l.seq.check(evt)
+	return evt, nil
+}
+
+// ReadEvents reads as many edge events as are available from the line
+// request, up to the size of the events buffer.
+//
+// Returns the number of events read.
+//
+// This function blocks until at least one event is available, with the same
+// caveats as ReadEvent.
+//
+// Only valid for lines requested with edge detection but without an event
+// handler or channel.
+//
+// Requires uAPI v2.
+func (l *baseLine) ReadEvents(events []LineEvent) (int, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+	if err := l.checkEventReader(); err != nil {
+		return 0, err
+	}
+	buf := make([]uapi.LineEvent, len(events))
+	n, err := uapi.ReadLineEvents(l.vfd, buf)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < n; i++ {
+		events[i] = newLineEvent(buf[i])
+		l.clocks.stamp(&events[i])
+		l.seq.check(events[i])
+	}
+	return n, nil
+}
+
+// EventStats returns the counters for edge events received from the line
+// request.
+func (l *baseLine) EventStats() EventStats {
+	var es EventStats
+	if l.shards != nil {
+		es = l.shards.eventStats()
+	} else {
+		es = l.seq.stats()
+		if l.filter != nil {
+			es.Filtered = l.filter.stats()
+		}
+	}
+	if l.queue != nil {
+		es.Dropped = l.queue.stats()
+	}
+	return es
+}
+
+// emulatedConfig updates the config in the info from the kernel to reflect
+// any config emulated in userspace.
+func (l *baseLine) emulatedConfig(info *LineInfo) {
+	if l.debouncer != nil {
+		if period, edges, ok := l.debouncer.config(info.Offset); ok {
+			info.Config.Debounced = true
+			info.Config.DebouncePeriod = period
+			info.Config.DebounceEmulated = true
+			info.Config.EdgeDetection = edges
+		}
+	}
+	if l.filter != nil {
+		info.Config.MinPulseWidth, info.Config.EdgePairWindow, info.Config.MaxEventRate =
+			l.filter.config(info.Offset)
+	}
+}
+
+// Line represents a single requested line.
+type Line struct {
+	baseLine
+}
+
+// Offset returns the offset of the line within the chip.
+func (l *Line) Offset() int {
+	return l.offsets[0]
+}
+
+// Info returns the information about the line.
+func (l *Line) Info() (info LineInfo, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		err = ErrClosed
+		return
+	}
+	if l.info != nil {
+		info = *l.info[0]
+		return
+	}
+	c, err := NewChip(l.chip, WithABIVersion(l.abi))
 	if err != nil {
 		return
 	}
@@ -890,6 +1704,7 @@ func (l *Line) Info() (info LineInfo, err error) {
 	if err != nil {
 		return
 	}
+	l.emulatedConfig(&inf)
 	l.info = []*LineInfo{&inf}
 	info = *l.info[0]
 	return
@@ -915,6 +1730,9 @@ func (l *Line) Value() (int, error) {
 // SetValue sets the current active state of the line.
 //
 // Only valid for output lines.
+//
+// Returns an ErrConstraintViolation, leaving the line unaltered, if the value
+// would violate a constraint provided WithConstraints.
 func (l *Line) SetValue(value int) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -924,22 +1742,24 @@ func (l *Line) SetValue(value int) error {
 	if l.closed {
 		return ErrClosed
 	}
+	if err := l.constraints.check(map[int]int{l.offsets[0]: value}); err != nil {
+		return err
+	}
+	var err error
 	if l.abi == 1 {
 		hd := uapi.HandleData{}
 		hd[0] = uint8(value)
-		err := uapi.SetLineValues(l.vfd, hd)
-		if err == nil {
-			l.values[l.offsets[0]] = value
+		err = uapi.SetLineValues(l.vfd, hd)
+	} else {
+		lsv := uapi.LineValues{
+			Mask: 1,
+			Bits: uapi.NewLineBitmap(value),
 		}
-		return err
+		err = uapi.SetLineValuesV2(l.vfd, lsv)
 	}
-	lsv := uapi.LineValues{
-		Mask: 1,
-		Bits: uapi.NewLineBitmap(value),
-	}
-	err := uapi.SetLineValuesV2(l.vfd, lsv)
 	if err == nil {
 		l.values[l.offsets[0]] = value
+		l.constraints.record(l.values)
 	}
 	return err
 }
@@ -964,6 +1784,14 @@ func (l *Lines) Info() ([]*LineInfo, error) {
 	if l.info != nil {
 		return l.info, nil
 	}
+	if l.shards != nil {
+		info := make([]*LineInfo, len(l.offsets))
+		if err := firstError(l.shards.info(info)); err != nil {
+			return nil, err
+		}
+		l.info = info
+		return l.info, nil
+	}
 	c, err := NewChip(l.chip, WithABIVersion(l.abi))
 	if err != nil {
 		return nil, err
@@ -975,6 +1803,7 @@ func (l *Lines) Info() ([]*LineInfo, error) {
 		if err != nil {
 			return nil, err
 		}
+		l.emulatedConfig(&inf)
 		info[i] = &inf
 	}
 	l.info = info
@@ -991,6 +1820,9 @@ func (l *Lines) Values(values []int) error {
 	if l.closed {
 		return ErrClosed
 	}
+	if l.shards != nil {
+		return firstError(l.shards.values(values))
+	}
 	lines := len(values)
 	if lines > len(l.offsets) {
 		lines = len(l.offsets)
@@ -1024,6 +1856,9 @@ func (l *Lines) Values(values []int) error {
 // All lines in the set are set at once.  If insufficient values are provided
 // then the remaining lines are set to inactive. If too many values are provided
 // then the surplus values are ignored.
+//
+// Returns an ErrConstraintViolation, leaving the lines unaltered, if the
+// values would violate a constraint provided WithConstraints.
 func (l *Lines) SetValues(values []int) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -1033,36 +1868,308 @@ func (l *Lines) SetValues(values []int) error {
 	if l.closed {
 		return ErrClosed
 	}
-	if len(values) > len(l.offsets) {
-		values = values[:len(l.offsets)]
+	if err := l.constraints.check(l.valuesMap(values)); err != nil {
+		return err
 	}
-	if l.abi == 1 {
+	return l.setValues(values)
+}
+
+// setValues sets the values of the requested lines, as per Lines.SetValues.
+//
+// The caller must hold the lock.
+func (l *baseLine) setValues(values []int) error {
+	// lines beyond the end of values are set inactive.
+	vv := make([]int, len(l.offsets))
+	copy(vv, values)
+	values = vv
+	var err error
+	if l.shards != nil {
+		err = firstError(l.shards.setValues(values))
+	} else if l.abi == 1 {
 		hd := uapi.HandleData{}
 		for i, v := range values {
 			hd[i] = uint8(v)
 		}
-		err := uapi.SetLineValues(l.vfd, hd)
-		if err == nil {
-			for i, v := range values {
-				l.values[l.offsets[i]] = v
-			}
+		err = uapi.SetLineValues(l.vfd, hd)
+	} else {
+		lv := uapi.LineValues{
+			Mask: uapi.NewLineBitMask(len(l.offsets)),
+			Bits: uapi.NewLineBitmap(values...),
 		}
-		return err
-	}
-	lv := uapi.LineValues{
-		Mask: uapi.NewLineBitMask(len(l.offsets)),
-		Bits: uapi.NewLineBitmap(values...),
+		err = uapi.SetLineValuesV2(l.vfd, lv)
 	}
-	err := uapi.SetLineValuesV2(l.vfd, lv)
 	if err == nil {
 		for i, v := range values {
 			l.values[l.offsets[i]] = v
 		}
+		l.constraints.record(l.values)
+	}
+	return err
+}
+
+// valuesMap returns the values, in request order, keyed by offset.
+//
+// Lines beyond the end of values are mapped to inactive.
+func (l *baseLine) valuesMap(values []int) map[int]int {
+	vv := make(map[int]int, len(l.offsets))
+	for i, o := range l.offsets {
+		if i < len(values) {
+			vv[o] = values[i]
+		} else {
+			vv[o] = 0
+		}
+	}
+	return vv
+}
+
+// ValuesSubset returns the current values (active state) of a subset of the
+// collection of lines.
+//
+// The values are returned in the same order as the offsets, which must all be
+// in the request.
+func (l *Lines) ValuesSubset(offsets []int) ([]int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, ErrClosed
+	}
+	positions, err := l.positions(offsets)
+	if err != nil {
+		return nil, err
+	}
+	return l.valuesAt(positions)
+}
+
+// valuesAt returns the current values of the lines at the given positions in
+// the request.
+//
+// The caller must hold the lock.
+func (l *baseLine) valuesAt(positions []int) ([]int, error) {
+	values := make([]int, len(positions))
+	if l.shards != nil {
+		err := firstError(l.shards.eachSubset(positions, func(r setRequest, idx, offsets []int) error {
+			vv, err := r.ll.ValuesSubset(offsets)
+			if err != nil {
+				return err
+			}
+			for i, v := range vv {
+				values[idx[i]] = v
+			}
+			return nil
+		}))
+		return values, err
+	}
+	if l.abi == 1 {
+		hd := uapi.HandleData{}
+		if err := uapi.GetLineValues(l.vfd, &hd); err != nil {
+			return nil, err
+		}
+		for i, p := range positions {
+			values[i] = int(hd[p])
+		}
+		return values, nil
+	}
+	lv := uapi.LineValues{Mask: uapi.NewLineBits(positions...)}
+	if err := uapi.GetLineValuesV2(l.vfd, &lv); err != nil {
+		return nil, err
+	}
+	for i, p := range positions {
+		values[i] = lv.Get(p)
+	}
+	return values, nil
+}
+
+// ValuesMap returns the current values (active state) of a subset of the
+// collection of lines.
+//
+// The subset is identified by the offsets keying the values map, which must
+// all be in the request, and the values in the map are overwritten with the
+// current values.
+func (l *Lines) ValuesMap(values map[int]int) error {
+	offsets := make([]int, 0, len(values))
+	for o := range values {
+		offsets = append(offsets, o)
+	}
+	vv, err := l.ValuesSubset(offsets)
+	if err != nil {
+		return err
+	}
+	for i, o := range offsets {
+		values[o] = vv[i]
 	}
+	return nil
+}
 
+// SetValuesSubset sets the current active state of a subset of the collection
+// of lines.
+//
+// Only valid for output lines.
+//
+// The values are in the same order as the offsets, which must all be in the
+// request.  If insufficient values are provided then the remaining lines in
+// the subset are set to inactive.  Lines not in the subset are left unaltered.
+//
+// The uAPI v1 does not support partial updates, so with v1 the lines not in
+// the subset are set to the values they were most recently set to.
+//
+// Constraints provided WithConstraints are checked as per SetValues.
+func (l *Lines) SetValuesSubset(offsets, values []int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.defCfg.Direction != LineDirectionOutput {
+		return ErrPermissionDenied
+	}
+	if l.closed {
+		return ErrClosed
+	}
+	positions, err := l.positions(offsets)
+	if err != nil {
+		return err
+	}
+	vv := make([]int, len(positions))
+	copy(vv, values)
+	if l.constraints != nil {
+		next := make(map[int]int, len(offsets))
+		for i, o := range offsets {
+			next[o] = vv[i]
+		}
+		if err := l.constraints.check(next); err != nil {
+			return err
+		}
+	}
+	if l.shards != nil {
+		err = firstError(l.shards.eachSubset(positions, func(r setRequest, idx, offsets []int) error {
+			sv := make([]int, len(idx))
+			for i, j := range idx {
+				sv[i] = vv[j]
+			}
+			return r.ll.SetValuesSubset(offsets, sv)
+		}))
+	} else if l.abi == 1 {
+		hd := uapi.HandleData{}
+		for i, o := range l.offsets {
+			hd[i] = uint8(l.values[o])
+		}
+		for i, p := range positions {
+			hd[p] = uint8(vv[i])
+		}
+		err = uapi.SetLineValues(l.vfd, hd)
+	} else {
+		lv := uapi.LineValues{Mask: uapi.NewLineBits(positions...)}
+		for i, p := range positions {
+			lv.Bits = lv.Bits.Set(p, vv[i])
+		}
+		err = uapi.SetLineValuesV2(l.vfd, lv)
+	}
+	if err == nil {
+		for i, o := range offsets {
+			l.values[o] = vv[i]
+		}
+		l.constraints.record(l.values)
+	}
 	return err
 }
 
+// SetValuesMap sets the current active state of a subset of the collection of
+// lines.
+//
+// The values are keyed by offset, which must all be in the request, and are
+// otherwise set as per SetValuesSubset.
+func (l *Lines) SetValuesMap(values map[int]int) error {
+	offsets := make([]int, 0, len(values))
+	vv := make([]int, 0, len(values))
+	for o, v := range values {
+		offsets = append(offsets, o)
+		vv = append(vv, v)
+	}
+	return l.SetValuesSubset(offsets, vv)
+}
+
+// SetLineEventHandler sets the handler for edge events from the line with
+// the given offset, replacing the handler for the request for that line.
+//
+// A nil handler removes the handler for the line, returning the line to the
+// handler for the request.
+//
+// The request must have been made with an event handler or channel, either
+// for the request or for individual lines, else ErrNoEventHandler is returned.
+func (l *Lines) SetLineEventHandler(offset int, eh EventHandler) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	if _, err := l.positions([]int{offset}); err != nil {
+		return err
+	}
+	if l.dispatcher == nil {
+		return ErrNoEventHandler
+	}
+	l.dispatcher.update(map[int]EventHandler{offset: eh})
+	return nil
+}
+
+// initialStates returns the initial state events for the lines that have
+// edge detection in lco, and did not have edge detection previously.
+//
+// The caller must hold the lock.
+func (l *baseLine) initialStates(lco lineConfigOptions) ([]LineEvent, error) {
+	var offsets []int
+	started := map[int]bool{}
+	for _, offset := range lco.offsets {
+		if lco.config(offset).EdgeDetection == LineEdgeNone {
+			continue
+		}
+		started[offset] = true
+		if !l.gate.started[offset] {
+			offsets = append(offsets, offset)
+		}
+	}
+	l.gate.started = started
+	if len(offsets) == 0 {
+		return nil, nil
+	}
+	positions, err := l.positions(offsets)
+	if err != nil {
+		return nil, err
+	}
+	values, err := l.valuesAt(positions)
+	if err != nil {
+		return nil, err
+	}
+	evts := make([]LineEvent, len(offsets))
+	for i, offset := range offsets {
+		clock := lco.config(offset).EventClock
+		evts[i] = LineEvent{
+			Offset:    offset,
+			Timestamp: timestamp(clock == LineEventClockRealtime),
+			Clock:     clock,
+			Type:      LineEventInitialState,
+			Value:     values[i],
+		}
+	}
+	return evts, nil
+}
+
+// positions returns the positions of the offsets within the request.
+func (l *baseLine) positions(offsets []int) ([]int, error) {
+	positions := make([]int, len(offsets))
+	for i, o := range offsets {
+		p := -1
+		for j, lo := range l.offsets {
+			if lo == o {
+				p = j
+				break
+			}
+		}
+		if p < 0 {
+			return nil, ErrInvalidOffset
+		}
+		positions[i] = p
+	}
+	return positions, nil
+}
+
 // LineEventType indicates the type of change to the line active state.
 //
 // Note that for active low lines a low line level results in a high active
@@ -1076,8 +2183,20 @@ const (
 
 	// LineEventFallingEdge indicates an active to inactive event.
 	LineEventFallingEdge
+
+	// LineEventInitialState indicates the state of the line when edge
+	// detection started, as requested by WithInitialStateEvents.
+	LineEventInitialState
 )
 
+// value returns the active state of the line following an edge of this type.
+func (t LineEventType) value() int {
+	if t == LineEventRisingEdge {
+		return 1
+	}
+	return 0
+}
+
 // LineEvent represents a change in the state of a line.
 type LineEvent struct {
 	// The line offset within the GPIO chip.
@@ -1091,12 +2210,18 @@ type LineEvent struct {
 	// CLOCK_MONOTONIC.
 	Timestamp time.Duration
 
+	// The clock the Timestamp is sourced from, as configured for the line.
+	Clock LineEventClock
+
 	// The type of state change event this structure represents.
 	Type LineEventType
 
+	// The active state of the line following the event.
+	Value int
+
 	// The seqno for this event in all events on all lines in this line request.
 	//
-	// Requires uAPI v2.
+	// Requires uAPI v2.  Zero for LineEventInitialState events.
 	Seqno uint32
 
 	// The seqno for this event in all events in this line.
@@ -1105,6 +2230,69 @@ type LineEvent struct {
 	LineSeqno uint32
 }
 
+// Time returns the time of the event, converted from the Timestamp using the
+// event Clock.
+//
+// Monotonic timestamps are converted to wall-clock time by applying the
+// current offset between CLOCK_MONOTONIC and CLOCK_REALTIME, so the result is
+// affected by any adjustment to the realtime clock since the event.
+// HTE timestamps are assumed to share the base of CLOCK_MONOTONIC.
+//
+// Events from kernels prior to v5.7 are timestamped using CLOCK_REALTIME,
+// irrespective of the Clock, so are not converted correctly.
+func (e LineEvent) Time() time.Time {
+	if e.Clock == LineEventClockRealtime {
+		return time.Unix(0, int64(e.Timestamp))
+	}
+	return time.Unix(0, int64(e.Timestamp+monotonicOffset()))
+}
+
+// monotonicOffset returns the current offset from CLOCK_MONOTONIC to
+// CLOCK_REALTIME.
+func monotonicOffset() time.Duration {
+	// bracket the monotonic read with realtime reads to reduce the error
+	rt1 := timestamp(true)
+	mono := timestamp(false)
+	rt2 := timestamp(true)
+	return rt1 + (rt2-rt1)/2 - mono
+}
+
+// LineEventOverflow describes edge events that were discarded by the kernel
+// as the event buffer for the line request overflowed.
+type LineEventOverflow struct {
+	// The offset of the line for the first event received after the loss.
+	Offset int
+
+	// The number of events discarded across all lines in the line request.
+	Dropped uint32
+
+	// The number of events discarded for the line at Offset.
+	LineDropped uint32
+
+	// Timestamp indicates the time the first event after the loss was
+	// detected.
+	Timestamp time.Duration
+}
+
+// EventStats contains counters for the edge events from a line request.
+type EventStats struct {
+	// Events is the number of edge events received from the kernel.
+	Events uint64
+
+	// Overflowed is the number of edge events discarded by the kernel as its
+	// event buffer overflowed.
+	//
+	// Requires uAPI v2.
+	Overflowed uint64
+
+	// Filtered is the number of edge events discarded by the event filters.
+	Filtered uint64
+
+	// Dropped is the number of edge events discarded as the event queue was
+	// full.
+	Dropped uint64
+}
+
 // LineInfoChangeEvent represents a change in the info a line.
 type LineInfoChangeEvent struct {
 	// Info is the updated line info.
@@ -1197,6 +2385,178 @@ func chipNames() []string {
 	return cc
 }
 
+// ChipSelector identifies a GPIO chip by one or more of its attributes.
+//
+// Fields that are empty are ignored, and a chip must match all of the fields
+// that are set.
+type ChipSelector struct {
+	// The name of the chip, e.g. gpiochip0, or the path to its device file.
+	Name string
+
+	// The label of the chip, as reported by Chip.Label.
+	Label string
+
+	// The sysfs path of the device providing the chip,
+	// e.g. /sys/devices/platform/soc/fe200000.gpio.
+	Device string
+
+	// The device tree node of the device providing the chip,
+	// e.g. /soc/gpio@7e200000, or its path under /proc/device-tree.
+	OfNode string
+}
+
+// ParseChipSelector converts a string identifying a chip into a
+// ChipSelector.
+//
+// The string may be:
+//
+//   - a chip name, e.g. gpiochip0
+//   - a chip number, e.g. 0
+//   - the path to the chip device file, e.g. /dev/gpiochip0
+//   - the sysfs path of the device providing the chip, e.g.
+//     /sys/devices/platform/soc/fe200000.gpio
+//   - the device tree node of the device providing the chip, prefixed with
+//     "of:", e.g. of:/soc/gpio@7e200000, or its path under /proc/device-tree
+//     or /sys/firmware/devicetree/base
+//   - the chip label, optionally prefixed with "label:", e.g. pinctrl-bcm2711
+//
+// Anything not matching one of the other forms is taken to be a label.
+func ParseChipSelector(s string) ChipSelector {
+	switch {
+	case strings.HasPrefix(s, "/dev/"), strings.HasPrefix(s, "gpiochip"):
+		return ChipSelector{Name: s}
+	case isChipNumber(s):
+		return ChipSelector{Name: "gpiochip" + s}
+	case strings.HasPrefix(s, "label:"):
+		return ChipSelector{Label: s[len("label:"):]}
+	case strings.HasPrefix(s, "of:"):
+		return ChipSelector{OfNode: s[len("of:"):]}
+	case strings.HasPrefix(s, "/proc/device-tree/"),
+		strings.HasPrefix(s, ofNodeBase+"/"):
+		return ChipSelector{OfNode: s}
+	case strings.HasPrefix(s, "/sys/"):
+		return ChipSelector{Device: s}
+	case len(s) == 0:
+		return ChipSelector{}
+	}
+	return ChipSelector{Label: s}
+}
+
+func isChipNumber(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ofNodeBase is the root of the device tree in sysfs.
+const ofNodeBase = "/sys/firmware/devicetree/base"
+
+// FindChip returns the name of the available chip matching the selector.
+//
+// Returns ErrChipNotFound if no chip matches, or ErrChipAmbiguous if more
+// than one chip matches.  An empty selector matches no chips.
+func FindChip(sel ChipSelector) (string, error) {
+	if sel == (ChipSelector{}) {
+		return "", ErrChipNotFound
+	}
+	m, err := newChipMatcher(sel)
+	if err != nil {
+		return "", err
+	}
+	found := ""
+	for _, name := range Chips() {
+		if !m.matches(name) {
+			continue
+		}
+		if len(found) != 0 {
+			return "", ErrChipAmbiguous
+		}
+		found = name
+	}
+	if len(found) == 0 {
+		return "", ErrChipNotFound
+	}
+	return found, nil
+}
+
+// chipMatcher is a ChipSelector with its paths resolved.
+type chipMatcher struct {
+	ChipSelector
+}
+
+func newChipMatcher(sel ChipSelector) (chipMatcher, error) {
+	m := chipMatcher{sel}
+	if len(sel.Name) != 0 {
+		m.Name = filepath.Base(nameToPath(sel.Name))
+	}
+	if len(sel.Device) != 0 {
+		p, err := filepath.EvalSymlinks(sel.Device)
+		if err != nil {
+			return m, ErrChipNotFound
+		}
+		m.Device = p
+	}
+	if len(sel.OfNode) != 0 {
+		node := sel.OfNode
+		if strings.HasPrefix(node, "/proc/device-tree/") {
+			node = node[len("/proc/device-tree"):]
+		}
+		if !strings.HasPrefix(node, ofNodeBase+"/") {
+			node = filepath.Join(ofNodeBase, node)
+		}
+		p, err := filepath.EvalSymlinks(node)
+		if err != nil {
+			return m, ErrChipNotFound
+		}
+		m.OfNode = p
+	}
+	return m, nil
+}
+
+func (m chipMatcher) matches(name string) bool {
+	if len(m.Name) != 0 && m.Name != name {
+		return false
+	}
+	if len(m.Device) != 0 || len(m.OfNode) != 0 {
+		// the chip device is a child of the device providing it
+		dev, err := filepath.EvalSymlinks("/sys/bus/gpio/devices/" + name)
+		if err != nil {
+			return false
+		}
+		parent := filepath.Dir(dev)
+		if len(m.Device) != 0 && m.Device != dev && m.Device != parent {
+			return false
+		}
+		if len(m.OfNode) != 0 &&
+			!isOfNode(dev, m.OfNode) && !isOfNode(parent, m.OfNode) {
+			return false
+		}
+	}
+	if len(m.Label) != 0 {
+		c, err := NewChip(name)
+		if err != nil {
+			return false
+		}
+		c.Close()
+		if c.Label != m.Label {
+			return false
+		}
+	}
+	return true
+}
+
+// isOfNode returns true if the device has the device tree node.
+func isOfNode(dev, node string) bool {
+	p, err := filepath.EvalSymlinks(dev + "/of_node")
+	return err == nil && p == node
+}
+
 func nameToPath(name string) string {
 	if strings.HasPrefix(name, "/dev/") {
 		return name
@@ -1205,27 +2565,189 @@ func nameToPath(name string) string {
 }
 
 var (
+	// ErrChipAmbiguous indicates more than one chip matches a ChipSelector.
+	ErrChipAmbiguous = errors.New("more than one chip matches selector")
+
+	// ErrChipNotFound indicates no chip matches a ChipSelector.
+	ErrChipNotFound = errors.New("chip not found")
+
 	// ErrClosed indicates the chip or line has already been closed.
 	ErrClosed = errors.New("already closed")
 
 	// ErrConfigOverflow indicates the provided configuration is too complicated
 	// to be mapped to the kernel uAPI.
 	//
-	// Reduce the number of line options or split the request into multiple
-	// requests for smaller sets of lines.
+	// Reduce the number of line options, split the request into multiple
+	// requests for smaller sets of lines, or use WithSharding to have the
+	// request split automatically.
 	ErrConfigOverflow = errors.New("configuration too complex to map to kernel uAPI")
 
+	// ErrEventWatcher indicates the operation is not available as the edge
+	// events for the line request are being delivered asynchronously, to an
+	// event handler or channel.
+	ErrEventWatcher = errors.New("edge events are being delivered by an event watcher")
+
 	// ErrInvalidOffset indicates a line offset is invalid.
 	ErrInvalidOffset = errors.New("invalid offset")
 
+	// ErrLeaseExpired indicates the lease has expired, or has otherwise ended
+	// as it was cancelled, replaced by a subsequent lease, or the lines were
+	// closed.
+	ErrLeaseExpired = errors.New("lease expired")
+
+	// ErrMultipleChips indicates the requested lines are spread over more
+	// than one chip, and so cannot be requested as a single collection.
+	ErrMultipleChips = errors.New("lines are on multiple chips")
+
+	// ErrNoEdgeDetection indicates the operation is not available as the line
+	// was requested without the necessary edge detection.
+	ErrNoEdgeDetection = errors.New("edge detection not enabled")
+
+	// ErrNoEventHandler indicates the operation is not available as the line
+	// request was made without an event handler or channel.
+	ErrNoEventHandler = errors.New("no event handler")
+
 	// ErrNotCharacterDevice indicates the device is not a character device.
 	ErrNotCharacterDevice = errors.New("not a character device")
 
 	// ErrPermissionDenied indicates caller does not have required permissions
 	// for the operation.
 	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrSharded indicates the operation is not available as the line
+	// request has been split into several kernel requests.
+	ErrSharded = errors.New("not supported by a sharded line request")
 )
 
+// ErrConstraintViolation indicates the values were not set as they would
+// violate a constraint provided WithConstraints.
+type ErrConstraintViolation struct {
+	// The constraint that would be violated.
+	Constraint Constraint
+
+	// The lines that would violate the constraint, identified by offset, or
+	// by position for a Group.
+	Lines []int
+
+	// The time until the values would be permitted, for constraints that
+	// restrict the timing of changes.
+	RetryAfter time.Duration
+}
+
+func (e ErrConstraintViolation) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s violated by lines %v, retry after %s", e.Constraint, e.Lines, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s violated by lines %v", e.Constraint, e.Lines)
+}
+
+// ErrDuplicateLineName indicates a line name has been provided more than once.
+type ErrDuplicateLineName struct {
+	Name string
+}
+
+func (e ErrDuplicateLineName) Error() string {
+	return fmt.Sprintf("line name '%s' is duplicated", e.Name)
+}
+
+// ErrGroup contains the errors returned for individual chips by an operation
+// on a Group.
+type ErrGroup struct {
+	// The errors, keyed by chip name.
+	Errs map[string]error
+}
+
+func (e ErrGroup) Error() string {
+	chips := make([]string, 0, len(e.Errs))
+	for c := range e.Errs {
+		chips = append(chips, c)
+	}
+	sort.Strings(chips)
+	ee := make([]string, len(chips))
+	for i, c := range chips {
+		ee[i] = fmt.Sprintf("%s: %s", c, e.Errs[c])
+	}
+	return fmt.Sprintf("group operation failed (%s)", strings.Join(ee, "; "))
+}
+
+// ErrLineBusy indicates a line could not be requested as it is held by
+// another request.
+type ErrLineBusy struct {
+	// The offset of the busy line.
+	Offset int
+
+	// The consumer holding the line, as reported by its LineInfo.
+	Consumer string
+
+	// The reason the request failed, i.e. unix.EBUSY, or the error from the
+	// context if the wait for the line to be released was abandoned.
+	Err error
+}
+
+func (e ErrLineBusy) Error() string {
+	return fmt.Sprintf("line %d is busy, held by '%s' (%s)", e.Offset, e.Consumer, e.Err)
+}
+
+// Unwrap returns the reason the request failed.
+func (e ErrLineBusy) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true for unix.EBUSY, so the error may be treated as the error
+// from the failed request.
+func (e ErrLineBusy) Is(target error) bool {
+	return target == unix.EBUSY
+}
+
+// ErrLineNameAmbiguous indicates more than one line has the given name.
+type ErrLineNameAmbiguous struct {
+	Name string
+
+	// The chips of the lines with the name.
+	Chips []string
+
+	// The offsets of the lines with the name, in the same order as Chips.
+	Offsets []int
+}
+
+func (e ErrLineNameAmbiguous) Error() string {
+	ll := make([]string, len(e.Chips))
+	for i, c := range e.Chips {
+		ll[i] = fmt.Sprintf("%s:%d", c, e.Offsets[i])
+	}
+	return fmt.Sprintf("line name '%s' is ambiguous (%s)", e.Name, strings.Join(ll, ", "))
+}
+
+// ErrLineNotFound indicates no line has the given name.
+type ErrLineNotFound struct {
+	Name string
+}
+
+func (e ErrLineNotFound) Error() string {
+	return fmt.Sprintf("line '%s' not found", e.Name)
+}
+
+// ErrSharedLineConflict indicates a shared line was requested with a
+// configuration that differs from that of the existing shared request.
+type ErrSharedLineConflict struct {
+	// The name of the chip containing the line.
+	Chip string
+
+	// The offset of the line.
+	Offset int
+
+	// The configuration of the existing shared request.
+	Config LineConfig
+
+	// The configuration requested.
+	Requested LineConfig
+}
+
+func (e ErrSharedLineConflict) Error() string {
+	return fmt.Sprintf("shared line %s:%d requested with conflicting config %+v, shared as %+v",
+		e.Chip, e.Offset, e.Requested, e.Config)
+}
+
 // ErrUapiIncompatibility indicates the feature is not supported by the given
 // kernel uAPI version.
 type ErrUapiIncompatibility struct {
@@ -153,3 +153,59 @@ func BenchmarkInterruptLatency(b *testing.B) {
 	}
 	r.Close()
 }
+
+func BenchmarkEventLineRequestClose(b *testing.B) {
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(b, err)
+	defer s.Close()
+	c, err := gpiod.NewChip(s.DevPath())
+	require.Nil(b, err)
+	require.NotNil(b, c)
+	defer c.Close()
+	eh := func(evt gpiod.LineEvent) {}
+	for i := 0; i < b.N; i++ {
+		r, _ := c.RequestLine(2,
+			gpiod.WithBothEdges,
+			gpiod.WithEventHandler(eh))
+		r.Close()
+	}
+}
+
+func BenchmarkInterruptLatencyMultiRequest(b *testing.B) {
+	s, err := gpiosim.NewSimpleton(32)
+	require.Nil(b, err)
+	defer s.Close()
+	c, err := gpiod.NewChip(s.DevPath())
+	require.Nil(b, err)
+	require.NotNil(b, c)
+	defer c.Close()
+	ich := make(chan int)
+	eh := func(evt gpiod.LineEvent) {
+		ich <- evt.Offset
+	}
+	// many individually requested lines, each with its own watcher.
+	for offset := 0; offset < s.Config().NumLines; offset++ {
+		s.SetPull(offset, 1)
+		r, err := c.RequestLine(offset,
+			gpiod.WithBothEdges,
+			gpiod.WithEventHandler(eh))
+		require.Nil(b, err)
+		require.NotNil(b, r)
+		defer r.Close()
+	}
+	// absorb any pending interrupts
+	for {
+		select {
+		case <-ich:
+			continue
+		case <-time.After(time.Millisecond):
+		}
+		break
+	}
+	offset := 2
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.SetPull(offset, i&1)
+		<-ich
+	}
+}
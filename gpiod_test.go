@@ -5,9 +5,14 @@
 package gpiod_test
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -70,17 +75,17 @@ func TestRequestLine(t *testing.T) {
 
 	// already requested input
 	l2, err := gpiod.RequestLine(s.DevPath(), offset)
-	assert.Equal(t, unix.EBUSY, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, l2)
 
 	// already requested output
 	l2, err = gpiod.RequestLine(s.DevPath(), offset, append(opts, gpiod.AsOutput(0))...)
-	assert.Equal(t, unix.EBUSY, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, l2)
 
 	// already requested output as event
 	l2, err = gpiod.RequestLine(s.DevPath(), offset, append(opts, gpiod.WithBothEdges)...)
-	assert.Equal(t, unix.EBUSY, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, l2)
 
 	err = l.Close()
@@ -120,23 +125,110 @@ func TestRequestLines(t *testing.T) {
 
 	// already requested input
 	ll2, err := gpiod.RequestLines(s.DevPath(), offsets)
-	assert.Equal(t, unix.EBUSY, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, ll2)
 
 	// already requested output
 	ll2, err = gpiod.RequestLines(s.DevPath(), offsets, append(opts, gpiod.AsOutput())...)
-	assert.Equal(t, unix.EBUSY, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, ll2)
 
 	// already requested output as event
 	ll2, err = gpiod.RequestLines(s.DevPath(), offsets, append(opts, gpiod.WithBothEdges)...)
-	assert.Equal(t, unix.EBUSY, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, ll2)
 
 	err = ll.Close()
 	assert.Nil(t, err)
 }
 
+// newNamedSim creates a sim with named lines spread over two chips.
+func newNamedSim(t *testing.T) *gpiosim.Sim {
+	s, err := gpiosim.NewSim(
+		gpiosim.WithName("gpiod_named_test"),
+		gpiosim.WithBank(gpiosim.NewBank("left", 8,
+			gpiosim.WithNamedLine(1, "GPIOD_TEST_L1"),
+			gpiosim.WithNamedLine(2, "GPIOD_TEST_L2"),
+			gpiosim.WithNamedLine(3, "GPIOD_TEST_DUP"),
+			gpiosim.WithNamedLine(5, "GPIOD_TEST_SPAN"),
+			gpiosim.WithNamedLine(6, "GPIOD_TEST_DUP"),
+		)),
+		gpiosim.WithBank(gpiosim.NewBank("right", 4,
+			gpiosim.WithNamedLine(0, "GPIOD_TEST_R0"),
+			gpiosim.WithNamedLine(2, "GPIOD_TEST_SPAN"),
+		)),
+	)
+	require.Nil(t, err)
+	return s
+}
+
+func TestFindLine(t *testing.T) {
+	s := newNamedSim(t)
+	defer s.Close()
+
+	// found
+	chip, offset, err := gpiod.FindLine("GPIOD_TEST_L2")
+	assert.Nil(t, err)
+	assert.Equal(t, s.Chips[0].ChipName(), chip)
+	assert.Equal(t, 2, offset)
+
+	chip, offset, err = gpiod.FindLine("GPIOD_TEST_R0")
+	assert.Nil(t, err)
+	assert.Equal(t, s.Chips[1].ChipName(), chip)
+	assert.Equal(t, 0, offset)
+
+	// not found
+	_, _, err = gpiod.FindLine("GPIOD_TEST_MISSING")
+	assert.Equal(t, gpiod.ErrLineNotFound{"GPIOD_TEST_MISSING"}, err)
+
+	// ambiguous
+	_, _, err = gpiod.FindLine("GPIOD_TEST_SPAN")
+	xerr := gpiod.ErrLineNameAmbiguous{
+		Name:    "GPIOD_TEST_SPAN",
+		Chips:   []string{s.Chips[0].ChipName(), s.Chips[1].ChipName()},
+		Offsets: []int{5, 2},
+	}
+	assert.Equal(t, xerr, err)
+}
+
+func TestRequestLinesByName(t *testing.T) {
+	s := newNamedSim(t)
+	defer s.Close()
+
+	// success
+	ll, err := gpiod.RequestLinesByName([]string{"GPIOD_TEST_L2", "GPIOD_TEST_L1"})
+	assert.Nil(t, err)
+	require.NotNil(t, ll)
+	assert.Equal(t, s.Chips[0].ChipName(), ll.Chip())
+	assert.Equal(t, []int{2, 1}, ll.Offsets())
+	ll.Close()
+
+	// not found
+	ll, err = gpiod.RequestLinesByName([]string{"GPIOD_TEST_L1", "GPIOD_TEST_MISSING"})
+	assert.Equal(t, gpiod.ErrLineNotFound{"GPIOD_TEST_MISSING"}, err)
+	assert.Nil(t, ll)
+
+	// duplicate
+	ll, err = gpiod.RequestLinesByName([]string{"GPIOD_TEST_L1", "GPIOD_TEST_L1"})
+	assert.Equal(t, gpiod.ErrDuplicateLineName{"GPIOD_TEST_L1"}, err)
+	assert.Nil(t, ll)
+
+	// ambiguous
+	ll, err = gpiod.RequestLinesByName([]string{"GPIOD_TEST_DUP"})
+	assert.IsType(t, gpiod.ErrLineNameAmbiguous{}, err)
+	assert.Nil(t, ll)
+
+	// multiple chips
+	ll, err = gpiod.RequestLinesByName([]string{"GPIOD_TEST_L1", "GPIOD_TEST_R0"})
+	assert.Equal(t, gpiod.ErrMultipleChips, err)
+	assert.Nil(t, ll)
+
+	// empty
+	ll, err = gpiod.RequestLinesByName(nil)
+	assert.Equal(t, unix.EINVAL, err)
+	assert.Nil(t, ll)
+}
+
 func TestNewChip(t *testing.T) {
 	var chipOpts []gpiod.ChipOption
 	if kernelAbiVersion != 0 {
@@ -169,6 +261,114 @@ func TestNewChip(t *testing.T) {
 	assert.Equal(t, s.Config().Label, c.Label)
 	err = c.Close()
 	assert.Nil(t, err)
+
+	// number
+	c, err = gpiod.NewChip(strings.TrimPrefix(s.ChipName(), "gpiochip"), chipOpts...)
+	assert.Nil(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, s.ChipName(), c.Name)
+	err = c.Close()
+	assert.Nil(t, err)
+
+	// label
+	c, err = gpiod.NewChip(s.Config().Label, chipOpts...)
+	assert.Nil(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, s.ChipName(), c.Name)
+	err = c.Close()
+	assert.Nil(t, err)
+
+	// symlink in /dev takes precedence over label
+	link := "gpiod_test_link"
+	err = os.Symlink(s.DevPath(), "/dev/"+link)
+	require.Nil(t, err)
+	defer os.Remove("/dev/" + link)
+	c, err = gpiod.NewChip(link, chipOpts...)
+	assert.Nil(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, s.ChipName(), c.Name)
+	err = c.Close()
+	assert.Nil(t, err)
+	c, err = gpiod.NewChip("label:"+link, chipOpts...)
+	assert.Equal(t, gpiod.ErrChipNotFound, err)
+	assert.Nil(t, c)
+
+	// unknown label
+	c, err = gpiod.NewChip("gpiod_test_nonexistent", chipOpts...)
+	assert.Equal(t, gpiod.ErrChipNotFound, err)
+	assert.Nil(t, c)
+}
+
+func TestParseChipSelector(t *testing.T) {
+	patterns := []struct {
+		in  string
+		sel gpiod.ChipSelector
+	}{
+		{"gpiochip1", gpiod.ChipSelector{Name: "gpiochip1"}},
+		{"/dev/gpiochip1", gpiod.ChipSelector{Name: "/dev/gpiochip1"}},
+		{"12", gpiod.ChipSelector{Name: "gpiochip12"}},
+		{"pinctrl-bcm2711", gpiod.ChipSelector{Label: "pinctrl-bcm2711"}},
+		{"label:12", gpiod.ChipSelector{Label: "12"}},
+		{"label:gpiochip1", gpiod.ChipSelector{Label: "gpiochip1"}},
+		{"/sys/devices/platform/soc/fe200000.gpio",
+			gpiod.ChipSelector{Device: "/sys/devices/platform/soc/fe200000.gpio"}},
+		{"of:/soc/gpio@7e200000", gpiod.ChipSelector{OfNode: "/soc/gpio@7e200000"}},
+		{"/proc/device-tree/soc/gpio@7e200000",
+			gpiod.ChipSelector{OfNode: "/proc/device-tree/soc/gpio@7e200000"}},
+		{"/sys/firmware/devicetree/base/soc/gpio@7e200000",
+			gpiod.ChipSelector{OfNode: "/sys/firmware/devicetree/base/soc/gpio@7e200000"}},
+		{"", gpiod.ChipSelector{}},
+	}
+	for _, p := range patterns {
+		t.Run(p.in, func(t *testing.T) {
+			assert.Equal(t, p.sel, gpiod.ParseChipSelector(p.in))
+		})
+	}
+}
+
+func TestFindChip(t *testing.T) {
+	s, err := gpiosim.NewSim(
+		gpiosim.WithName("gpiod_find_chip_test"),
+		gpiosim.WithBank(gpiosim.NewBank("gpiod_test_find_a", 4)),
+		gpiosim.WithBank(gpiosim.NewBank("gpiod_test_find_b", 4)),
+	)
+	require.Nil(t, err)
+	defer s.Close()
+	chipA := s.Chips[0].ChipName()
+	chipB := s.Chips[1].ChipName()
+	dev, err := filepath.EvalSymlinks("/sys/bus/gpio/devices/" + chipA)
+	require.Nil(t, err)
+	parent := filepath.Dir(dev)
+
+	patterns := []struct {
+		name string
+		sel  gpiod.ChipSelector
+		chip string
+		err  error
+	}{
+		{"name", gpiod.ChipSelector{Name: chipB}, chipB, nil},
+		{"path", gpiod.ChipSelector{Name: s.Chips[1].DevPath()}, chipB, nil},
+		{"label", gpiod.ChipSelector{Label: "gpiod_test_find_b"}, chipB, nil},
+		{"device", gpiod.ChipSelector{Device: dev}, chipA, nil},
+		{"parent", gpiod.ChipSelector{Device: parent}, "", gpiod.ErrChipAmbiguous},
+		{"parent and label",
+			gpiod.ChipSelector{Device: parent, Label: "gpiod_test_find_b"},
+			chipB, nil},
+		{"name and label",
+			gpiod.ChipSelector{Name: chipA, Label: "gpiod_test_find_b"},
+			"", gpiod.ErrChipNotFound},
+		{"unknown label", gpiod.ChipSelector{Label: "gpiod_test_find_c"}, "", gpiod.ErrChipNotFound},
+		{"unknown device", gpiod.ChipSelector{Device: parent + "/nonexistent"}, "", gpiod.ErrChipNotFound},
+		{"unknown node", gpiod.ChipSelector{OfNode: "/gpiod_test_nonexistent"}, "", gpiod.ErrChipNotFound},
+		{"empty", gpiod.ChipSelector{}, "", gpiod.ErrChipNotFound},
+	}
+	for _, p := range patterns {
+		t.Run(p.name, func(t *testing.T) {
+			chip, err := gpiod.FindChip(p.sel)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.chip, chip)
+		})
+	}
 }
 
 func TestChips(t *testing.T) {
@@ -296,23 +496,146 @@ func TestChipRequestLine(t *testing.T) {
 
 	// already requested input
 	l2, err := c.RequestLine(offset)
-	assert.Equal(t, unix.EBUSY, err)
+	xerr := gpiod.ErrLineBusy{Offset: offset, Consumer: fmt.Sprintf("gpiod-%d", os.Getpid()), Err: unix.EBUSY}
+	assert.Equal(t, xerr, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, l2)
 
 	// already requested output
 	l2, err = c.RequestLine(offset, gpiod.AsOutput(0))
-	assert.Equal(t, unix.EBUSY, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, l2)
 
 	// already requested output as event
 	l2, err = c.RequestLine(offset, gpiod.WithBothEdges)
-	assert.Equal(t, unix.EBUSY, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, l2)
 
 	err = l.Close()
 	assert.Nil(t, err)
 }
 
+func TestChipRequestSharedLine(t *testing.T) {
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	offset := 3
+
+	// out of range
+	h, err := c.RequestSharedLine(c.Lines())
+	assert.Equal(t, gpiod.ErrInvalidOffset, err)
+	require.Nil(t, h)
+
+	// shared
+	ch1 := make(chan gpiod.LineEvent, 3)
+	h1, err := c.RequestSharedLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithInitialStateEvents,
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			ch1 <- evt
+		}))
+	assert.Nil(t, err)
+	require.NotNil(t, h1)
+	waitOffsetEvent(t, ch1, offset, gpiod.LineEventInitialState)
+	ch2 := make(chan gpiod.LineEvent, 3)
+	h2, err := c.RequestSharedLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventChannel(context.Background(), ch2))
+	assert.Nil(t, err)
+	require.NotNil(t, h2)
+	assert.Equal(t, c.Name, h2.Chip())
+	assert.Equal(t, offset, h2.Offset())
+
+	// events fanned out
+	s.SetPull(offset, 1)
+	waitOffsetEvent(t, ch1, offset, gpiod.LineEventRisingEdge)
+	waitOffsetEvent(t, ch2, offset, gpiod.LineEventRisingEdge)
+	v, err := h2.Value()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v)
+
+	// conflicting config
+	h, err = c.RequestSharedLine(offset, gpiod.AsOutput(0))
+	assert.IsType(t, gpiod.ErrSharedLineConflict{}, err)
+	require.Nil(t, h)
+
+	// unshared
+	l, err := c.RequestLine(offset)
+	assert.True(t, errors.Is(err, unix.EBUSY))
+	require.Nil(t, l)
+
+	// closing one handle leaves the line requested
+	err = h1.Close()
+	assert.Nil(t, err)
+	err = h1.Close()
+	assert.Equal(t, gpiod.ErrClosed, err)
+	_, err = h1.Value()
+	assert.Equal(t, gpiod.ErrClosed, err)
+	s.SetPull(offset, 0)
+	waitOffsetEvent(t, ch2, offset, gpiod.LineEventFallingEdge)
+	waitNoEvent(t, ch1)
+	inf, err := c.LineInfo(offset)
+	assert.Nil(t, err)
+	assert.True(t, inf.Used)
+
+	// closing the last handle releases the line, and closes its channel
+	err = h2.Close()
+	assert.Nil(t, err)
+	_, ok := <-ch2
+	assert.False(t, ok)
+	inf, err = c.LineInfo(offset)
+	assert.Nil(t, err)
+	assert.False(t, inf.Used)
+
+	// handlers may request and close other shared lines
+	h3, err := c.RequestSharedLine(1)
+	assert.Nil(t, err)
+	require.NotNil(t, h3)
+	done := make(chan error, 1)
+	h1, err = c.RequestSharedLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			err := h3.Close()
+			if err == nil {
+				var h4 *gpiod.SharedLine
+				if h4, err = c.RequestSharedLine(2); err == nil {
+					err = h4.Close()
+				}
+			}
+			done <- err
+		}))
+	assert.Nil(t, err)
+	require.NotNil(t, h1)
+	s.SetPull(offset, 1)
+	select {
+	case err = <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for handler")
+	}
+	err = h1.Close()
+	assert.Nil(t, err)
+
+	// output, with a new configuration once released
+	h1, err = c.RequestSharedLine(offset, gpiod.AsOutput(0))
+	assert.Nil(t, err)
+	require.NotNil(t, h1)
+	defer h1.Close()
+	h2, err = c.RequestSharedLine(offset, gpiod.AsOutput(1))
+	assert.Nil(t, err)
+	require.NotNil(t, h2)
+	defer h2.Close()
+	err = h2.SetValue(1)
+	assert.Nil(t, err)
+	v, err = s.Level(offset)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v)
+}
+
 func TestChipRequestLines(t *testing.T) {
 	offsets := []int{4, 2}
 	s, err := gpiosim.NewSimpleton(6)
@@ -339,23 +662,96 @@ func TestChipRequestLines(t *testing.T) {
 
 	// already requested input
 	ll2, err := c.RequestLines(offsets)
-	assert.Equal(t, unix.EBUSY, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, ll2)
 
 	// already requested output
 	ll2, err = c.RequestLines(offsets, gpiod.AsOutput())
-	assert.Equal(t, unix.EBUSY, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, ll2)
 
 	// already requested output as event
 	ll2, err = c.RequestLines(offsets, gpiod.WithBothEdges)
-	assert.Equal(t, unix.EBUSY, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
 	require.Nil(t, ll2)
 
 	err = ll.Close()
 	assert.Nil(t, err)
 }
 
+func TestChipFindLine(t *testing.T) {
+	s := newNamedSim(t)
+	defer s.Close()
+	c := getChip(t, s.Chips[1].DevPath())
+
+	// found
+	offset, err := c.FindLine("GPIOD_TEST_SPAN")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, offset)
+
+	// only on other chip
+	_, err = c.FindLine("GPIOD_TEST_L1")
+	assert.Equal(t, gpiod.ErrLineNotFound{"GPIOD_TEST_L1"}, err)
+
+	// unnamed
+	_, err = c.FindLine("")
+	assert.Equal(t, gpiod.ErrLineNotFound{""}, err)
+
+	c.Close()
+	c = getChip(t, s.Chips[0].DevPath())
+
+	// ambiguous
+	_, err = c.FindLine("GPIOD_TEST_DUP")
+	xerr := gpiod.ErrLineNameAmbiguous{
+		Name:    "GPIOD_TEST_DUP",
+		Chips:   []string{c.Name, c.Name},
+		Offsets: []int{3, 6},
+	}
+	assert.Equal(t, xerr, err)
+
+	// closed
+	c.Close()
+	_, err = c.FindLine("GPIOD_TEST_L1")
+	assert.Equal(t, gpiod.ErrClosed, err)
+}
+
+func TestChipRequestLinesByName(t *testing.T) {
+	s := newNamedSim(t)
+	defer s.Close()
+	c := getChip(t, s.Chips[0].DevPath())
+	defer c.Close()
+
+	// success
+	ll, err := c.RequestLinesByName([]string{"GPIOD_TEST_SPAN", "GPIOD_TEST_L1"}, gpiod.AsOutput(1, 0))
+	assert.Nil(t, err)
+	require.NotNil(t, ll)
+	assert.Equal(t, []int{5, 1}, ll.Offsets())
+	v, err := s.Chips[0].Level(5)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v)
+
+	// already requested
+	ll2, err := c.RequestLinesByName([]string{"GPIOD_TEST_L1"})
+	assert.True(t, errors.Is(err, unix.EBUSY))
+	assert.Nil(t, ll2)
+	ll.Close()
+
+	// not found
+	ll, err = c.RequestLinesByName([]string{"GPIOD_TEST_R0"})
+	assert.Equal(t, gpiod.ErrLineNotFound{"GPIOD_TEST_R0"}, err)
+	assert.Nil(t, ll)
+
+	// duplicate
+	ll, err = c.RequestLinesByName([]string{"GPIOD_TEST_L2", "GPIOD_TEST_L1", "GPIOD_TEST_L2"})
+	assert.Equal(t, gpiod.ErrDuplicateLineName{"GPIOD_TEST_L2"}, err)
+	assert.Nil(t, ll)
+
+	// ambiguous
+	ll, err = c.RequestLinesByName([]string{"GPIOD_TEST_DUP"})
+	assert.IsType(t, gpiod.ErrLineNameAmbiguous{}, err)
+	assert.Nil(t, ll)
+}
+
 func TestChipWatchLineInfo(t *testing.T) {
 	requireKernel(t, infoWatchKernel)
 
@@ -789,6 +1185,357 @@ func TestLineSetValue(t *testing.T) {
 	assert.Equal(t, gpiod.ErrClosed, err)
 }
 
+func TestLineSetValueFor(t *testing.T) {
+	offset := 2
+	ttl := 50 * time.Millisecond
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	// input
+	l, err := c.RequestLine(offset)
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	ls, err := l.SetValueFor(1, ttl)
+	assert.Equal(t, gpiod.ErrPermissionDenied, err)
+	assert.Nil(t, ls)
+	l.Close()
+
+	// output
+	l, err = c.RequestLine(offset, gpiod.AsOutput(0))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	ls, err = l.SetValueFor(1, ttl)
+	assert.Nil(t, err)
+	require.NotNil(t, ls)
+	v, err := s.Level(offset)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v)
+
+	// renew
+	for i := 0; i < 4; i++ {
+		time.Sleep(ttl / 2)
+		err = ls.Renew()
+		assert.Nil(t, err)
+	}
+	v, err = s.Level(offset)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v)
+
+	// expire
+	select {
+	case <-ls.Done():
+	case <-time.After(4 * ttl):
+		t.Fatal("lease not expired")
+	}
+	assert.Nil(t, ls.Err())
+	v, err = s.Level(offset)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, v)
+	err = ls.Renew()
+	assert.Equal(t, gpiod.ErrLeaseExpired, err)
+
+	// cancel
+	ls, err = l.SetValueFor(1, ttl)
+	assert.Nil(t, err)
+	require.NotNil(t, ls)
+	err = ls.Cancel()
+	assert.Nil(t, err)
+	time.Sleep(2 * ttl)
+	v, err = s.Level(offset)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v)
+
+	// closed
+	l.Close()
+	ls, err = l.SetValueFor(1, ttl)
+	assert.Equal(t, gpiod.ErrClosed, err)
+	assert.Nil(t, ls)
+
+	// expiry deferred by constraint
+	l, err = c.RequestLine(offset,
+		gpiod.AsOutput(0),
+		gpiod.WithConstraints(gpiod.MinToggleInterval(4*ttl, offset)))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	start := time.Now()
+	ls, err = l.SetValueFor(1, ttl)
+	assert.Nil(t, err)
+	require.NotNil(t, ls)
+	select {
+	case <-ls.Done():
+	case <-time.After(8 * ttl):
+		t.Fatal("lease not expired")
+	}
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(4*ttl))
+	assert.Nil(t, ls.Err())
+	v, err = s.Level(offset)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, v)
+}
+
+func TestLineReadEvent(t *testing.T) {
+	offset := 3
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	s.SetPull(offset, 0)
+	l, err := c.RequestLine(offset, gpiod.WithBothEdges)
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	if c.UapiAbiVersion() == 1 {
+		_, err = l.WaitEvent(0)
+		xerr := gpiod.ErrUapiIncompatibility{"synchronous event reading", 1}
+		assert.Equal(t, xerr, err)
+		_, err = l.ReadEvent()
+		assert.Equal(t, xerr, err)
+		l.Close()
+		return
+	}
+	ok, err := l.WaitEvent(0)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	s.SetPull(offset, 1)
+	ok, err = l.WaitEvent(time.Second)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	evt, err := l.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, offset, evt.Offset)
+	assert.Equal(t, gpiod.LineEventRisingEdge, evt.Type)
+	assert.Equal(t, uint32(1), evt.Seqno)
+	s.SetPull(offset, 0)
+	evt, err = l.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, gpiod.LineEventFallingEdge, evt.Type)
+	assert.Equal(t, uint32(2), evt.Seqno)
+	ok, err = l.WaitEvent(20 * time.Millisecond)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	l.Close()
+	_, err = l.WaitEvent(0)
+	assert.Equal(t, gpiod.ErrClosed, err)
+	_, err = l.ReadEvent()
+	assert.Equal(t, gpiod.ErrClosed, err)
+
+	// with handler
+	l, err = c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(func(gpiod.LineEvent) {}))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	_, err = l.WaitEvent(0)
+	assert.Equal(t, gpiod.ErrEventWatcher, err)
+	_, err = l.ReadEvent()
+	assert.Equal(t, gpiod.ErrEventWatcher, err)
+	l.Close()
+}
+
+func TestLinesReadEvents(t *testing.T) {
+	offsets := []int{2, 3, 4}
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+	requireABI(t, c, 2)
+
+	for _, o := range offsets {
+		s.SetPull(o, 0)
+	}
+	l, err := c.RequestLines(offsets, gpiod.WithBothEdges)
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	for _, o := range offsets {
+		s.SetPull(o, 1)
+	}
+	ok, err := l.WaitEvent(time.Second)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	time.Sleep(10 * time.Millisecond)
+	events := make([]gpiod.LineEvent, 5)
+	n, err := l.ReadEvents(events)
+	assert.Nil(t, err)
+	assert.Equal(t, len(offsets), n)
+	for i, o := range offsets {
+		assert.Equal(t, o, events[i].Offset)
+		assert.Equal(t, gpiod.LineEventRisingEdge, events[i].Type)
+		assert.Equal(t, uint32(i+1), events[i].Seqno)
+	}
+	ok, err = l.WaitEvent(0)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestLineEventTime(t *testing.T) {
+	// realtime
+	now := time.Now()
+	evt := gpiod.LineEvent{
+		Timestamp: time.Duration(now.UnixNano()),
+		Clock:     gpiod.LineEventClockRealtime,
+	}
+	assert.True(t, now.Equal(evt.Time()))
+
+	// monotonic
+	var ts unix.Timespec
+	unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts)
+	now = time.Now()
+	evt = gpiod.LineEvent{Timestamp: time.Duration(ts.Nano())}
+	assert.WithinDuration(t, now, evt.Time(), time.Millisecond)
+
+	// from the kernel
+	offset := 2
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+	ich := make(chan gpiod.LineEvent, 3)
+	l, err := c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			ich <- evt
+		}))
+	require.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	now = time.Now()
+	s.SetPull(offset, 1)
+	select {
+	case evt := <-ich:
+		assert.Equal(t, gpiod.LineEventClockMonotonic, evt.Clock)
+		assert.WithinDuration(t, now, evt.Time(), 10*time.Millisecond)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+	if l.UapiAbiVersion() == 1 {
+		return
+	}
+	err = l.Reconfigure(gpiod.WithRealtimeEventClock)
+	assert.Nil(t, err)
+	now = time.Now()
+	s.SetPull(offset, 0)
+	select {
+	case evt := <-ich:
+		assert.Equal(t, gpiod.LineEventClockRealtime, evt.Clock)
+		assert.WithinDuration(t, now, evt.Time(), 10*time.Millisecond)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+}
+
+func TestLineWaitFor(t *testing.T) {
+	offset := 2
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	// no edge detection
+	s.SetPull(offset, 0)
+	l, err := c.RequestLine(offset, gpiod.WithRisingEdge)
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = l.WaitFor(ctx, 0)
+	assert.Equal(t, gpiod.ErrNoEdgeDetection, err)
+	l.Close()
+
+	patterns := []struct {
+		name string
+		opts []gpiod.LineReqOption
+	}{
+		{"handler", []gpiod.LineReqOption{gpiod.WithEventHandler(func(gpiod.LineEvent) {})}},
+		{"direct", nil},
+	}
+	for _, p := range patterns {
+		tf := func(t *testing.T) {
+			if len(p.opts) == 0 && c.UapiAbiVersion() == 1 {
+				t.Skip("direct event reading requires uAPI v2")
+			}
+			s.SetPull(offset, 0)
+			opts := append([]gpiod.LineReqOption{gpiod.WithBothEdges}, p.opts...)
+			l, err := c.RequestLine(offset, opts...)
+			assert.Nil(t, err)
+			require.NotNil(t, l)
+			defer l.Close()
+
+			// already at value
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			err = l.WaitFor(ctx, 0)
+			assert.Nil(t, err)
+
+			// edge
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				s.SetPull(offset, 1)
+			}()
+			err = l.WaitFor(ctx, 1)
+			assert.Nil(t, err)
+
+			// timeout
+			tctx, tcancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer tcancel()
+			err = l.WaitFor(tctx, 0)
+			assert.Equal(t, context.DeadlineExceeded, err)
+
+			// expired and very short deadlines
+			for _, d := range []time.Duration{-time.Millisecond, 0, time.Microsecond, 100 * time.Microsecond, time.Millisecond} {
+				tctx, tcancel := context.WithTimeout(context.Background(), d)
+				done := make(chan error, 1)
+				go func() {
+					done <- l.WaitFor(tctx, 0)
+				}()
+				select {
+				case err = <-done:
+					assert.Equal(t, context.DeadlineExceeded, err, d)
+				case <-time.After(time.Second):
+					assert.Fail(t, "WaitFor hung past its deadline", d)
+				}
+				tcancel()
+			}
+		}
+		t.Run(p.name, tf)
+	}
+
+	// edge dropped by the event queue
+	s.SetPull(offset, 0)
+	release := make(chan struct{})
+	l, err = c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(func(gpiod.LineEvent) { <-release }),
+		gpiod.WithEventQueue(1, gpiod.EventQueueDropNewest))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	defer close(release)
+	// the first edge is held by the handler, and the second fills the queue.
+	s.SetPull(offset, 1)
+	time.Sleep(20 * time.Millisecond)
+	s.SetPull(offset, 0)
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.SetPull(offset, 1)
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = l.WaitFor(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), l.EventStats().Dropped)
+}
+
 func TestLinesChip(t *testing.T) {
 	offsets := []int{5, 4, 3}
 	s, err := gpiosim.NewSimpleton(6)
@@ -973,6 +1720,293 @@ func TestLinesSetValues(t *testing.T) {
 	assert.Equal(t, gpiod.ErrClosed, err)
 }
 
+func TestLinesValuesSubset(t *testing.T) {
+	offsets := []int{2, 3, 1, 5}
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	l, err := c.RequestLines(offsets, gpiod.AsInput)
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	s.SetPull(2, 1)
+	s.SetPull(3, 0)
+	s.SetPull(1, 1)
+	s.SetPull(5, 0)
+
+	vv, err := l.ValuesSubset([]int{5, 1})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 1}, vv)
+
+	vm := map[int]int{2: 0, 3: 1}
+	err = l.ValuesMap(vm)
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]int{2: 1, 3: 0}, vm)
+
+	// not requested
+	vv, err = l.ValuesSubset([]int{5, 4})
+	assert.Equal(t, gpiod.ErrInvalidOffset, err)
+	assert.Nil(t, vv)
+
+	// closed
+	l.Close()
+	_, err = l.ValuesSubset([]int{5, 1})
+	assert.Equal(t, gpiod.ErrClosed, err)
+}
+
+func TestLinesSetValuesSubset(t *testing.T) {
+	offsets := []int{2, 3, 1, 5}
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	// input
+	l, err := c.RequestLines(offsets)
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	err = l.SetValuesSubset([]int{2}, []int{1})
+	assert.Equal(t, gpiod.ErrPermissionDenied, err)
+	l.Close()
+
+	// output
+	l, err = c.RequestLines(offsets, gpiod.AsOutput(1, 0, 1, 0))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	err = l.SetValuesSubset([]int{5, 2}, []int{1, 0})
+	assert.Nil(t, err)
+	checkLevels(t, s, offsets, []int{0, 0, 1, 1})
+
+	// insufficient values
+	err = l.SetValuesSubset([]int{1, 3}, []int{0})
+	assert.Nil(t, err)
+	checkLevels(t, s, offsets, []int{0, 0, 0, 1})
+
+	err = l.SetValuesMap(map[int]int{3: 1, 1: 1})
+	assert.Nil(t, err)
+	checkLevels(t, s, offsets, []int{0, 1, 1, 1})
+
+	// not requested
+	err = l.SetValuesSubset([]int{2, 4}, []int{1, 1})
+	assert.Equal(t, gpiod.ErrInvalidOffset, err)
+	checkLevels(t, s, offsets, []int{0, 1, 1, 1})
+
+	// closed
+	l.Close()
+	err = l.SetValuesMap(map[int]int{3: 0})
+	assert.Equal(t, gpiod.ErrClosed, err)
+}
+
+func TestLinesSetLineEventHandler(t *testing.T) {
+	offsets := []int{2, 3}
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	// no event handler
+	l, err := c.RequestLines(offsets, gpiod.WithBothEdges)
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	err = l.SetLineEventHandler(3, func(gpiod.LineEvent) {})
+	assert.Equal(t, gpiod.ErrNoEventHandler, err)
+	err = l.Reconfigure(gpiod.WithLines([]int{3}, gpiod.WithEventHandler(nil)))
+	assert.Equal(t, gpiod.ErrNoEventHandler, err)
+	l.Close()
+
+	// per-line handler
+	ich := make(chan gpiod.LineEvent, 3)
+	lch := make(chan gpiod.LineEvent, 3)
+	l, err = c.RequestLines(offsets,
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			ich <- evt
+		}),
+		gpiod.WithLines([]int{3}, gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			lch <- evt
+		})))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	s.SetPull(3, 1)
+	waitOffsetEvent(t, lch, 3, gpiod.LineEventRisingEdge)
+	waitNoEvent(t, ich)
+	s.SetPull(2, 1)
+	waitOffsetEvent(t, ich, 2, gpiod.LineEventRisingEdge)
+	waitNoEvent(t, lch)
+
+	// removed
+	err = l.SetLineEventHandler(3, nil)
+	assert.Nil(t, err)
+	s.SetPull(3, 0)
+	waitOffsetEvent(t, ich, 3, gpiod.LineEventFallingEdge)
+	waitNoEvent(t, lch)
+
+	// via reconfigure
+	err = l.Reconfigure(gpiod.WithLines([]int{2}, gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+		lch <- evt
+	})))
+	assert.Nil(t, err)
+	s.SetPull(2, 0)
+	waitOffsetEvent(t, lch, 2, gpiod.LineEventFallingEdge)
+	waitNoEvent(t, ich)
+
+	// not requested
+	err = l.SetLineEventHandler(4, nil)
+	assert.Equal(t, gpiod.ErrInvalidOffset, err)
+
+	// closed
+	l.Close()
+	err = l.SetLineEventHandler(3, nil)
+	assert.Equal(t, gpiod.ErrClosed, err)
+}
+
+func TestLinesWaitForAny(t *testing.T) {
+	offsets := []int{2, 3, 5}
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	for _, o := range offsets {
+		s.SetPull(o, 0)
+	}
+	l, err := c.RequestLines(offsets,
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(func(gpiod.LineEvent) {}))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// already at value
+	offset, err := l.WaitForAny(ctx, map[int]int{2: 1, 5: 0})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, offset)
+
+	// edge
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.SetPull(3, 1)
+	}()
+	offset, err = l.WaitForAny(ctx, map[int]int{2: 1, 3: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, offset)
+
+	// not requested
+	_, err = l.WaitForAny(ctx, map[int]int{4: 1})
+	assert.Equal(t, gpiod.ErrInvalidOffset, err)
+
+	// closed while waiting
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		l.Close()
+	}()
+	_, err = l.WaitForAny(ctx, map[int]int{2: 1})
+	assert.Equal(t, gpiod.ErrClosed, err)
+}
+
+func TestLinesExport(t *testing.T) {
+	offsets := []int{1, 2}
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	l, err := c.RequestLines(offsets, gpiod.AsOutput(1, 0), gpiod.WithConsumer("exporter"))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	checkLevels(t, s, offsets, []int{1, 0})
+
+	// stream socket
+	ssrc, sdst := newUnixConnPair(t, unix.SOCK_STREAM)
+	defer ssrc.Close()
+	defer sdst.Close()
+	err = l.Export(ssrc)
+	assert.Equal(t, unix.EPROTOTYPE, err)
+	l1, err := gpiod.ImportLine(sdst)
+	assert.Equal(t, unix.EPROTOTYPE, err)
+	require.Nil(t, l1)
+
+	src, dst := newUnixConnPair(t, unix.SOCK_SEQPACKET)
+	defer src.Close()
+	defer dst.Close()
+	err = l.Export(src)
+	assert.Nil(t, err)
+	err = l.SetValues([]int{0, 0})
+	assert.Equal(t, gpiod.ErrClosed, err)
+	err = l.Export(src)
+	assert.Equal(t, gpiod.ErrClosed, err)
+
+	// in flight - still requested and undisturbed
+	inf, err := c.LineInfo(offsets[0])
+	assert.Nil(t, err)
+	assert.True(t, inf.Used)
+	assert.Equal(t, "exporter", inf.Consumer)
+	checkLevels(t, s, offsets, []int{1, 0})
+
+	// not a single line - left on the socket
+	l1, err = gpiod.ImportLine(dst)
+	assert.Equal(t, gpiod.ErrInvalidOffset, err)
+	require.Nil(t, l1)
+	inf, err = c.LineInfo(offsets[0])
+	assert.Nil(t, err)
+	assert.True(t, inf.Used)
+	checkLevels(t, s, offsets, []int{1, 0})
+
+	l, err = gpiod.ImportLines(dst)
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	assert.Equal(t, offsets, l.Offsets())
+	assert.Equal(t, c.Name, l.Chip())
+	checkLevels(t, s, offsets, []int{1, 0})
+	vv := make([]int, len(offsets))
+	err = l.Values(vv)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 0}, vv)
+	err = l.SetValues([]int{1, 1})
+	assert.Nil(t, err)
+	checkLevels(t, s, offsets, []int{1, 1})
+	err = l.Close()
+	assert.Nil(t, err)
+	inf, err = c.LineInfo(offsets[0])
+	assert.Nil(t, err)
+	assert.False(t, inf.Used)
+
+	// events read directly block on an imported request
+	requireABI(t, c, 2)
+	offset := offsets[0]
+	s.SetPull(offset, 0)
+	l1, err = c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(func(gpiod.LineEvent) {}))
+	assert.Nil(t, err)
+	require.NotNil(t, l1)
+	err = l1.Export(src)
+	assert.Nil(t, err)
+	l1, err = gpiod.ImportLine(dst)
+	assert.Nil(t, err)
+	require.NotNil(t, l1)
+	defer l1.Close()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.SetPull(offset, 1)
+	}()
+	evt, err := l1.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, offset, evt.Offset)
+	assert.Equal(t, gpiod.LineEventRisingEdge, evt.Type)
+}
+
 func TestIsChip(t *testing.T) {
 	// nonexistent
 	err := gpiod.IsChip("/dev/nonexistent")
@@ -989,6 +2023,32 @@ func TestIsChip(t *testing.T) {
 	// not sure how to test the remaining conditions...
 }
 
+func waitOffsetEvent(t *testing.T, ch <-chan gpiod.LineEvent, offset int, etype gpiod.LineEventType) {
+	t.Helper()
+	select {
+	case evt := <-ch:
+		assert.Equal(t, offset, evt.Offset)
+		assert.Equal(t, etype, evt.Type)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+}
+
+func newUnixConnPair(t *testing.T, typ int) (*net.UnixConn, *net.UnixConn) {
+	t.Helper()
+	fds, err := unix.Socketpair(unix.AF_UNIX, typ|unix.SOCK_CLOEXEC, 0)
+	require.Nil(t, err)
+	conns := make([]*net.UnixConn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		conn, err := net.FileConn(f)
+		f.Close()
+		require.Nil(t, err)
+		conns[i] = conn.(*net.UnixConn)
+	}
+	return conns[0], conns[1]
+}
+
 func waitInfoEvent(t *testing.T, ch <-chan gpiod.LineInfoChangeEvent, etype gpiod.LineInfoChangeType) {
 	t.Helper()
 	select {
@@ -0,0 +1,554 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// GroupLine identifies a line to be included in a Group.
+type GroupLine struct {
+	// The chip containing the line, in any of the forms accepted by NewChip.
+	Chip string
+
+	// The offset of the line on the chip.
+	Offset int
+}
+
+// GroupEvent is an edge event from a line in a Group, or from a line request
+// made using WithGroupEventHandler.
+type GroupEvent struct {
+	// The position of the line in the request.
+	Line int
+
+	// The name of the chip containing the line.
+	Chip string
+
+	LineEvent
+}
+
+// Group represents a collection of requested lines that may span multiple
+// chips.
+//
+// The lines are requested from each chip in a separate kernel request, so
+// operations on a Group are not atomic across chips.
+//
+// Lines are identified by their position in the Group, both in value slices
+// and in line configuration options, so WithLines selects lines by their
+// position rather than their offset.
+type Group struct {
+	// the lines, with Chip converted to the chip name.
+	lines []GroupLine
+
+	// the per-chip requests.
+	rs requestSet
+
+	// serializes events across chips.
+	evtmu *sync.Mutex
+
+	// mu covers all that follow.
+	mu     sync.Mutex
+	lco    lineConfigOptions
+	ech    *eventChannel
+	closed bool
+
+	// the configuration applied when the lines are released.
+	releaseState []LineConfigOption
+
+	// the constraints on the values of output lines, by position.
+	constraints *constraints
+}
+
+// RequestGroup requests control of a collection of lines that may span
+// multiple chips.
+//
+// The options are applied to the Group as a whole, with lines identified by
+// their position in the lines slice, e.g. AsOutput(1, 0) sets the first line
+// in the lines slice high and the second low, irrespective of their chips.
+//
+// Edge events from all chips are passed to the event handler, or channel,
+// serially.  A GroupEventHandler is recommended, as events are then
+// identified by their position in the Group rather than by offset, which may
+// be ambiguous across chips.
+//
+// Failures are returned as an ErrGroup containing the errors for the
+// individual chips.  If any request fails then none of the lines are
+// requested.
+//
+// If granted, control is maintained until the Group is closed.
+func RequestGroup(lines []GroupLine, options ...LineReqOption) (*Group, error) {
+	if len(lines) == 0 {
+		// as the kernel would for an empty request
+		return nil, unix.EINVAL
+	}
+	positions := make([]int, len(lines))
+	for i := range positions {
+		positions[i] = i
+	}
+	lro := lineReqOptions{
+		lineConfigOptions: lineConfigOptions{
+			offsets: positions,
+			values:  map[int]int{},
+		},
+	}
+	for _, option := range options {
+		option.applyLineReqOption(&lro)
+	}
+	cs, err := newConstraints(lro.constraints, positions, lro.drivenValues())
+	if err != nil {
+		return nil, err
+	}
+	if err := cs.checkOptions(lro.lineConfigOptions, lro.releaseState); err != nil {
+		return nil, err
+	}
+	// group the lines by chip, in the order the chips first appear
+	var chips []*Chip
+	opened := map[string]*Chip{}
+	byName := map[string]*Chip{}
+	chipLines := map[*Chip][]int{}
+	defer func() {
+		for _, c := range chips {
+			c.Close()
+		}
+	}()
+	g := &Group{lines: make([]GroupLine, len(lines)), constraints: cs}
+	for i, gl := range lines {
+		c := opened[gl.Chip]
+		if c == nil {
+			var err error
+			c, err = NewChip(gl.Chip)
+			if err != nil {
+				return nil, ErrGroup{map[string]error{gl.Chip: err}}
+			}
+			if cc := byName[c.Name]; cc != nil {
+				c.Close()
+				c = cc
+			} else {
+				byName[c.Name] = c
+				chips = append(chips, c)
+			}
+			opened[gl.Chip] = c
+		}
+		g.lines[i] = GroupLine{Chip: c.Name, Offset: gl.Offset}
+		chipLines[c] = append(chipLines[c], i)
+	}
+	if lro.ech != nil {
+		g.ech = newEventChannel(lro.ech.ctx, lro.ech.ch)
+		lro.eh = g.ech.handle
+	}
+	// serialize events across chips
+	g.evtmu = &sync.Mutex{}
+	mu := g.evtmu
+	g.serializeHandlers(lro.handlers)
+	for _, c := range chips {
+		positions := chipLines[c]
+		offsets := make([]int, len(positions))
+		for i, p := range positions {
+			offsets[i] = g.lines[p].Offset
+			if offsets[i] < 0 || offsets[i] >= c.lines {
+				g.rs.close()
+				return nil, ErrGroup{map[string]error{c.Name: ErrInvalidOffset}}
+			}
+		}
+		clro := lineReqOptions{
+			lineConfigOptions: lro.subset(positions, offsets),
+			consumer:          lro.consumer,
+			abi:               lro.abi,
+			eventBufferSize:   lro.eventBufferSize,
+			shard:             lro.shard,
+			pollPeriod:        lro.pollPeriod,
+			softDebounce:      lro.softDebounce,
+			queueSize:         lro.queueSize,
+			queuePolicy:       lro.queuePolicy,
+			workers:           lro.workers,
+			initialState:      lro.initialState,
+			waitCtx:           lro.waitCtx,
+		}
+		if len(clro.consumer) == 0 {
+			clro.consumer = c.options.consumer
+		}
+		if clro.abi == 0 {
+			clro.abi = c.options.abi
+		}
+		if lro.geh != nil {
+			clro.eh = serializeEvents(mu, groupEventHandler(lro.geh, positions).forLines(c.Name, offsets))
+		} else if lro.eh != nil {
+			clro.eh = serializeEvents(mu, lro.eh)
+		}
+		if lro.oh != nil {
+			oh := lro.oh
+			clro.oh = func(ovf LineEventOverflow) {
+				mu.Lock()
+				defer mu.Unlock()
+				oh(ovf)
+			}
+		}
+		ll := &Lines{}
+		if err := c.requestOrWait(&ll.baseLine, clro); err != nil {
+			g.rs.close()
+			return nil, ErrGroup{map[string]error{c.Name: err}}
+		}
+		g.rs = append(g.rs, setRequest{chip: c.Name, ll: ll, lines: positions})
+	}
+	g.lco = lro.lineConfigOptions
+	g.lco.handlers = nil
+	if g.ech != nil {
+		g.ech.start()
+	}
+	if len(lro.releaseState) != 0 {
+		g.releaseState = lro.releaseState
+		registerReleaser(g)
+	}
+	return g, nil
+}
+
+// RequestGroupByName requests control of a collection of lines, identified
+// by name, that may span multiple chips.
+//
+// The lines are located as per FindLine, and are otherwise requested as per
+// RequestGroup.
+func RequestGroupByName(names []string, options ...LineReqOption) (*Group, error) {
+	ll, err := findLines(Chips(), names)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]GroupLine, len(ll))
+	for i, l := range ll {
+		lines[i] = GroupLine{Chip: l.chip, Offset: l.offset}
+	}
+	return RequestGroup(lines, options...)
+}
+
+// groupEventHandler returns a GroupEventHandler that converts the position
+// of the line in a chip request to its position in the group.
+func groupEventHandler(geh GroupEventHandler, positions []int) GroupEventHandler {
+	return func(evt GroupEvent) {
+		evt.Line = positions[evt.Line]
+		geh(evt)
+	}
+}
+
+// serializeEvents returns an EventHandler that calls eh while holding mu.
+func serializeEvents(mu *sync.Mutex, eh EventHandler) EventHandler {
+	return func(evt LineEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		eh(evt)
+	}
+}
+
+// serializeHandlers wraps the per-line event handlers so that they are
+// serialized with the events from other chips.
+func (g *Group) serializeHandlers(handlers map[int]EventHandler) {
+	for p, eh := range handlers {
+		if eh != nil {
+			handlers[p] = serializeEvents(g.evtmu, eh)
+		}
+	}
+}
+
+// Lines returns the lines in the Group.
+//
+// The chip of each line is identified by name, e.g. gpiochip0.
+func (g *Group) Lines() []GroupLine {
+	return append([]GroupLine(nil), g.lines...)
+}
+
+// Close releases all the requested lines.
+//
+// If the lines were requested WithReleaseState then the release state is
+// applied before the lines are released, regardless of any constraints, with
+// lines identified by their position in the Group.
+func (g *Group) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrClosed
+	}
+	var rerr error
+	if g.releaseState != nil {
+		unregisterReleaser(g)
+		rerr = g.release()
+	}
+	g.closed = true
+	if g.ech != nil {
+		g.ech.stop()
+	}
+	errs := g.rs.close()
+	if g.ech != nil {
+		g.ech.close()
+	}
+	if errs != nil {
+		return g.rs.groupError(errs)
+	}
+	return rerr
+}
+
+// Info returns the information about the lines, in the same order as
+// the lines were requested.
+func (g *Group) Info() ([]*LineInfo, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil, ErrClosed
+	}
+	info := make([]*LineInfo, len(g.lines))
+	errs := g.rs.info(info)
+	return info, g.rs.groupError(errs)
+}
+
+// Reconfigure updates the configuration of the requested lines.
+//
+// Configuration for lines on all chips are updated, even if the update for
+// some chips fails.  In that case the configuration of the Group is not
+// altered, so the next Reconfigure applies its options to the previous
+// configuration, and restores that to the chips that were updated.
+//
+// Lines are identified by their position in the Group, both in value slices
+// and in WithLines.
+func (g *Group) Reconfigure(options ...LineConfigOption) error {
+	if len(options) == 0 {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrClosed
+	}
+	return g.reconfigure(options)
+}
+
+// reconfigure applies the options to the configuration of the lines.
+//
+// The caller must hold the lock.
+//
+// The configuration of the Group is only updated if all the requests are
+// successfully reconfigured, else the previous configuration is retained, and
+// is reapplied to all the requests by the next reconfigure.
+//
+// Returns an ErrConstraintViolation, without altering the lines, if the
+// values resulting from the configuration violate the constraints.
+func (g *Group) reconfigure(options []LineConfigOption) error {
+	lco := g.configWith(options)
+	if err := g.constraints.check(lco.drivenValues()); err != nil {
+		return err
+	}
+	return g.reconfigureUnchecked(lco)
+}
+
+// configWith returns a copy of the configuration of the lines with the
+// options applied.
+//
+// The caller must hold the lock.
+func (g *Group) configWith(options []LineConfigOption) lineConfigOptions {
+	// a copy, as the options alter the config in place.
+	lco := g.lco.subset(g.lco.offsets, g.lco.offsets)
+	for _, option := range options {
+		option.applyLineConfigOption(&lco)
+	}
+	return lco
+}
+
+// reconfigureUnchecked applies the configuration to the lines without
+// checking the constraints.
+//
+// The caller must hold the lock.
+func (g *Group) reconfigureUnchecked(lco lineConfigOptions) error {
+	driven := lco.drivenValues()
+	g.serializeHandlers(lco.handlers)
+	errs := g.rs.reconfigure(lco)
+	// the handlers are changes, so are not retained.
+	lco.handlers = nil
+	g.recordValues(driven, errs)
+	if errs == nil {
+		g.lco = lco
+	}
+	return g.rs.groupError(errs)
+}
+
+// applyReleaseState applies the release state to the lines.
+func (g *Group) applyReleaseState() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrClosed
+	}
+	return g.release()
+}
+
+// release applies the release state to the lines, regardless of the
+// constraints, as per baseLine.release.
+//
+// The caller must hold the lock.
+func (g *Group) release() error {
+	return g.reconfigureUnchecked(g.configWith(g.releaseState))
+}
+
+// Values returns the current values of the requested lines.
+//
+// The values are returned in the order the lines were requested, and the
+// values slice should be at least that long.  Any additional lines are not
+// returned.
+//
+// The values of lines on all chips are read, even if the read from some
+// chips fails.
+func (g *Group) Values(values []int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrClosed
+	}
+	return g.rs.groupError(g.rs.values(values))
+}
+
+// SetValues sets the current values of the requested lines.
+//
+// The values are in the order the lines were requested.  Lines beyond the
+// end of the values slice are set inactive.
+//
+// The values of lines on all chips are set, even if the set for some chips
+// fails.  No lines are set if the values would violate a constraint provided
+// WithConstraints, and an ErrConstraintViolation is returned instead.
+func (g *Group) SetValues(values []int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrClosed
+	}
+	next := make(map[int]int, len(g.lines))
+	for p := range g.lines {
+		if p < len(values) {
+			next[p] = values[p]
+		} else {
+			next[p] = 0
+		}
+	}
+	if err := g.constraints.check(next); err != nil {
+		return err
+	}
+	errs := g.rs.setValues(values)
+	g.recordValues(next, errs)
+	return g.rs.groupError(errs)
+}
+
+// recordValues records the values set on the lines, other than those of the
+// requests that failed, for checking against the constraints.
+//
+// The caller must hold the lock.
+func (g *Group) recordValues(values map[int]int, errs []error) {
+	if g.constraints == nil {
+		return
+	}
+	set := map[int]int{}
+	for i, r := range g.rs {
+		if errs != nil && errs[i] != nil {
+			continue
+		}
+		for _, p := range r.lines {
+			if v, ok := values[p]; ok {
+				set[p] = v
+			}
+		}
+	}
+	g.constraints.record(set)
+}
+
+// requestSet is a set of line requests that are operated on as one, with
+// each line identified by its position in the set.
+type requestSet []setRequest
+
+// setRequest is a line request within a requestSet.
+type setRequest struct {
+	chip string
+	ll   *Lines
+
+	// the position in the set of each of the lines in ll.
+	lines []int
+}
+
+// each calls fn for each of the requests.
+//
+// Returns the errors for the individual requests, in the same order as the
+// requests, or nil if there were no errors.
+func (rs requestSet) each(fn func(r setRequest) error) []error {
+	var errs []error
+	for i, r := range rs {
+		if err := fn(r); err != nil {
+			if errs == nil {
+				errs = make([]error, len(rs))
+			}
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+func (rs requestSet) close() []error {
+	return rs.each(func(r setRequest) error {
+		return r.ll.Close()
+	})
+}
+
+func (rs requestSet) info(info []*LineInfo) []error {
+	return rs.each(func(r setRequest) error {
+		ii, err := r.ll.Info()
+		if err != nil {
+			return err
+		}
+		for i, p := range r.lines {
+			info[p] = ii[i]
+		}
+		return nil
+	})
+}
+
+func (rs requestSet) reconfigure(lco lineConfigOptions) []error {
+	return rs.each(func(r setRequest) error {
+		return r.ll.applyConfig(lco.subset(r.lines, r.ll.offsets))
+	})
+}
+
+func (rs requestSet) values(values []int) []error {
+	return rs.each(func(r setRequest) error {
+		vv := make([]int, len(r.lines))
+		if err := r.ll.Values(vv); err != nil {
+			return err
+		}
+		for i, p := range r.lines {
+			if p < len(values) {
+				values[p] = vv[i]
+			}
+		}
+		return nil
+	})
+}
+
+func (rs requestSet) setValues(values []int) []error {
+	return rs.each(func(r setRequest) error {
+		vv := make([]int, len(r.lines))
+		for i, p := range r.lines {
+			if p < len(values) {
+				vv[i] = values[p]
+			}
+		}
+		return r.ll.SetValues(vv)
+	})
+}
+
+// groupError converts the errors from the requests into an ErrGroup.
+func (rs requestSet) groupError(errs []error) error {
+	if errs == nil {
+		return nil
+	}
+	e := ErrGroup{Errs: map[string]error{}}
+	for i, err := range errs {
+		if err != nil {
+			e.Errs[rs[i].chip] = err
+		}
+	}
+	return e
+}
@@ -0,0 +1,317 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/taemon1337/gpiod"
+	"github.com/warthog618/go-gpiosim"
+	"golang.org/x/sys/unix"
+)
+
+func newGroupSim(t *testing.T) (*gpiosim.Sim, []gpiod.GroupLine) {
+	s, err := gpiosim.NewSim(
+		gpiosim.WithName("gpiod_group_test"),
+		gpiosim.WithBank(gpiosim.NewBank("gpiod_test_group_a", 6,
+			gpiosim.WithNamedLine(2, "GPIOD_GROUP_A2"),
+		)),
+		gpiosim.WithBank(gpiosim.NewBank("gpiod_test_group_b", 6,
+			gpiosim.WithNamedLine(2, "GPIOD_GROUP_B2"),
+		)),
+	)
+	require.Nil(t, err)
+	lines := []gpiod.GroupLine{
+		{Chip: s.Chips[0].ChipName(), Offset: 2},
+		{Chip: s.Chips[1].ChipName(), Offset: 2},
+		{Chip: s.Chips[0].DevPath(), Offset: 4},
+	}
+	return s, lines
+}
+
+func TestRequestGroup(t *testing.T) {
+	s, lines := newGroupSim(t)
+	defer s.Close()
+
+	// empty
+	g, err := gpiod.RequestGroup(nil)
+	assert.Equal(t, unix.EINVAL, err)
+	assert.Nil(t, g)
+
+	// non-existent chip
+	g, err = gpiod.RequestGroup(append(lines, gpiod.GroupLine{Chip: "gpiod_test_nonexistent"}))
+	xerr := gpiod.ErrGroup{Errs: map[string]error{"gpiod_test_nonexistent": gpiod.ErrChipNotFound}}
+	assert.Equal(t, xerr, err)
+	assert.Nil(t, g)
+
+	// invalid offset
+	g, err = gpiod.RequestGroup(append(lines, gpiod.GroupLine{Chip: s.Chips[1].ChipName(), Offset: 6}))
+	xerr = gpiod.ErrGroup{Errs: map[string]error{s.Chips[1].ChipName(): gpiod.ErrInvalidOffset}}
+	assert.Equal(t, xerr, err)
+	assert.Nil(t, g)
+
+	// success
+	g, err = gpiod.RequestGroup(lines, gpiod.AsOutput(1, 0, 1))
+	assert.Nil(t, err)
+	require.NotNil(t, g)
+	xlines := []gpiod.GroupLine{
+		{Chip: s.Chips[0].ChipName(), Offset: 2},
+		{Chip: s.Chips[1].ChipName(), Offset: 2},
+		{Chip: s.Chips[0].ChipName(), Offset: 4},
+	}
+	assert.Equal(t, xlines, g.Lines())
+	v, _ := s.Chips[0].Level(2)
+	assert.Equal(t, 1, v)
+	v, _ = s.Chips[1].Level(2)
+	assert.Equal(t, 0, v)
+	v, _ = s.Chips[0].Level(4)
+	assert.Equal(t, 1, v)
+
+	// already requested - no lines left requested
+	g2, err := gpiod.RequestGroup([]gpiod.GroupLine{
+		{Chip: s.Chips[1].ChipName(), Offset: 1},
+		{Chip: s.Chips[0].ChipName(), Offset: 4},
+	})
+	busy := gpiod.ErrLineBusy{Offset: 4, Consumer: fmt.Sprintf("gpiod-%d", os.Getpid()), Err: unix.EBUSY}
+	xerr = gpiod.ErrGroup{Errs: map[string]error{s.Chips[0].ChipName(): busy}}
+	assert.Equal(t, xerr, err)
+	assert.Nil(t, g2)
+	l, err := gpiod.RequestLine(s.Chips[1].ChipName(), 1)
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	l.Close()
+
+	err = g.Close()
+	assert.Nil(t, err)
+	err = g.Close()
+	assert.Equal(t, gpiod.ErrClosed, err)
+
+	// by name
+	g, err = gpiod.RequestGroupByName([]string{"GPIOD_GROUP_B2", "GPIOD_GROUP_A2"})
+	assert.Nil(t, err)
+	require.NotNil(t, g)
+	xlines = []gpiod.GroupLine{
+		{Chip: s.Chips[1].ChipName(), Offset: 2},
+		{Chip: s.Chips[0].ChipName(), Offset: 2},
+	}
+	assert.Equal(t, xlines, g.Lines())
+	g.Close()
+}
+
+func TestGroupValues(t *testing.T) {
+	s, lines := newGroupSim(t)
+	defer s.Close()
+
+	g, err := gpiod.RequestGroup(lines, gpiod.AsInput)
+	assert.Nil(t, err)
+	require.NotNil(t, g)
+
+	s.Chips[0].SetPull(2, 1)
+	s.Chips[1].SetPull(2, 0)
+	s.Chips[0].SetPull(4, 1)
+	vv := make([]int, 3)
+	err = g.Values(vv)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 0, 1}, vv)
+
+	s.Chips[0].SetPull(2, 0)
+	s.Chips[1].SetPull(2, 1)
+	vv = make([]int, 2)
+	err = g.Values(vv)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 1}, vv)
+
+	g.Close()
+	err = g.Values(vv)
+	assert.Equal(t, gpiod.ErrClosed, err)
+}
+
+func TestGroupSetValues(t *testing.T) {
+	s, lines := newGroupSim(t)
+	defer s.Close()
+
+	// input
+	g, err := gpiod.RequestGroup(lines)
+	assert.Nil(t, err)
+	require.NotNil(t, g)
+	err = g.SetValues([]int{1, 1, 1})
+	assert.IsType(t, gpiod.ErrGroup{}, err)
+	assert.Equal(t, 2, len(err.(gpiod.ErrGroup).Errs))
+	g.Close()
+
+	// output
+	g, err = gpiod.RequestGroup(lines, gpiod.AsOutput())
+	assert.Nil(t, err)
+	require.NotNil(t, g)
+	err = g.SetValues([]int{0, 1, 1})
+	assert.Nil(t, err)
+	v, _ := s.Chips[0].Level(2)
+	assert.Equal(t, 0, v)
+	v, _ = s.Chips[1].Level(2)
+	assert.Equal(t, 1, v)
+	v, _ = s.Chips[0].Level(4)
+	assert.Equal(t, 1, v)
+
+	// short
+	err = g.SetValues([]int{1})
+	assert.Nil(t, err)
+	v, _ = s.Chips[0].Level(2)
+	assert.Equal(t, 1, v)
+	v, _ = s.Chips[1].Level(2)
+	assert.Equal(t, 0, v)
+	v, _ = s.Chips[0].Level(4)
+	assert.Equal(t, 0, v)
+
+	g.Close()
+	err = g.SetValues([]int{1})
+	assert.Equal(t, gpiod.ErrClosed, err)
+
+	// constrained, by position across chips
+	g, err = gpiod.RequestGroup(lines,
+		gpiod.AsOutput(),
+		gpiod.WithConstraints(gpiod.MutuallyExclusive(0, 1)))
+	assert.Nil(t, err)
+	require.NotNil(t, g)
+	defer g.Close()
+	err = g.SetValues([]int{1, 1, 1})
+	assert.Equal(t, gpiod.ErrConstraintViolation{
+		Constraint: gpiod.MutuallyExclusive(0, 1),
+		Lines:      []int{0, 1},
+	}, err)
+	v, _ = s.Chips[0].Level(4)
+	assert.Equal(t, 0, v)
+	err = g.SetValues([]int{0, 1, 1})
+	assert.Nil(t, err)
+	v, _ = s.Chips[1].Level(2)
+	assert.Equal(t, 1, v)
+}
+
+func TestGroupReconfigure(t *testing.T) {
+	requireKernel(t, uapiV2Kernel)
+	s, lines := newGroupSim(t)
+	defer s.Close()
+	c := getChip(t, s.Chips[0].DevPath())
+	requireABI(t, c, 2)
+	c.Close()
+
+	g, err := gpiod.RequestGroup(lines, gpiod.AsInput)
+	assert.Nil(t, err)
+	require.NotNil(t, g)
+	defer g.Close()
+
+	// lines identified by position
+	err = g.Reconfigure(gpiod.AsOutput(1, 1, 0), gpiod.WithLines([]int{2}, gpiod.AsActiveLow))
+	assert.Nil(t, err)
+	v, _ := s.Chips[0].Level(2)
+	assert.Equal(t, 1, v)
+	v, _ = s.Chips[1].Level(2)
+	assert.Equal(t, 1, v)
+	v, _ = s.Chips[0].Level(4)
+	assert.Equal(t, 1, v)
+	info, err := g.Info()
+	assert.Nil(t, err)
+	require.Equal(t, 3, len(info))
+	assert.Equal(t, gpiod.LineDirectionOutput, info[1].Config.Direction)
+	assert.False(t, info[0].Config.ActiveLow)
+	assert.False(t, info[1].Config.ActiveLow)
+	assert.True(t, info[2].Config.ActiveLow)
+
+	// failed reconfigure retains config - gpio-sim has no HTE provider
+	err = g.Reconfigure(gpiod.AsInput,
+		gpiod.WithLines([]int{1}, gpiod.WithBothEdges, gpiod.WithHTEEventClock))
+	assert.NotNil(t, err)
+	err = g.Reconfigure(gpiod.WithLines([]int{0}, gpiod.AsActiveLow))
+	assert.Nil(t, err)
+	info, err = g.Info()
+	assert.Nil(t, err)
+	require.Equal(t, 3, len(info))
+	for _, inf := range info {
+		assert.Equal(t, gpiod.LineDirectionOutput, inf.Config.Direction)
+		assert.Equal(t, gpiod.LineEdgeNone, inf.Config.EdgeDetection)
+	}
+	assert.True(t, info[0].Config.ActiveLow)
+	assert.False(t, info[1].Config.ActiveLow)
+	assert.True(t, info[2].Config.ActiveLow)
+}
+
+func TestGroupInfo(t *testing.T) {
+	s, lines := newGroupSim(t)
+	defer s.Close()
+
+	g, err := gpiod.RequestGroup(lines, gpiod.WithConsumer("gpiod_test_group"))
+	assert.Nil(t, err)
+	require.NotNil(t, g)
+	info, err := g.Info()
+	assert.Nil(t, err)
+	require.Equal(t, 3, len(info))
+	assert.Equal(t, "GPIOD_GROUP_A2", info[0].Name)
+	assert.Equal(t, "GPIOD_GROUP_B2", info[1].Name)
+	assert.Equal(t, 4, info[2].Offset)
+	for _, inf := range info {
+		assert.True(t, inf.Used)
+		assert.Equal(t, "gpiod_test_group", inf.Consumer)
+	}
+	g.Close()
+	_, err = g.Info()
+	assert.Equal(t, gpiod.ErrClosed, err)
+}
+
+func TestGroupEvents(t *testing.T) {
+	s, lines := newGroupSim(t)
+	defer s.Close()
+
+	// group event handler
+	ch := make(chan gpiod.GroupEvent, 3)
+	g, err := gpiod.RequestGroup(lines,
+		gpiod.WithBothEdges,
+		gpiod.WithGroupEventHandler(func(evt gpiod.GroupEvent) {
+			ch <- evt
+		}))
+	assert.Nil(t, err)
+	require.NotNil(t, g)
+	s.Chips[1].SetPull(2, 1)
+	select {
+	case evt := <-ch:
+		assert.Equal(t, 1, evt.Line)
+		assert.Equal(t, s.Chips[1].ChipName(), evt.Chip)
+		assert.Equal(t, 2, evt.Offset)
+		assert.Equal(t, gpiod.LineEventRisingEdge, evt.Type)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+	s.Chips[0].SetPull(4, 1)
+	select {
+	case evt := <-ch:
+		assert.Equal(t, 2, evt.Line)
+		assert.Equal(t, s.Chips[0].ChipName(), evt.Chip)
+		assert.Equal(t, 4, evt.Offset)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+	g.Close()
+
+	// event channel
+	ech := make(chan gpiod.LineEvent, 3)
+	g, err = gpiod.RequestGroup(lines,
+		gpiod.WithBothEdges,
+		gpiod.WithEventChannel(context.Background(), ech))
+	assert.Nil(t, err)
+	require.NotNil(t, g)
+	s.Chips[0].SetPull(2, 1)
+	select {
+	case evt := <-ech:
+		assert.Equal(t, 2, evt.Offset)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+	g.Close()
+	waitClosed(t, ech)
+}
@@ -0,0 +1,334 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// exportedRequest is the state of an exported line request, as sent
+// alongside its file descriptor.
+type exportedRequest struct {
+	Chip       string
+	ABI        int
+	Offsets    []int
+	Values     map[int]int
+	Config     LineConfig
+	LineConfig map[int]*LineConfig
+}
+
+// maxExportSize is the maximum size of the encoded state of an exported line
+// request.
+const maxExportSize = 1 << 16
+
+// maxReceivedFds is the number of file descriptors that may be received with
+// an exported line request.  Only one is expected, and any others are closed.
+const maxReceivedFds = 8
+
+// Export passes the line request to another process over the Unix domain
+// socket, from where it may be reconstructed by ImportLine or ImportLines.
+//
+// The socket must preserve message boundaries, i.e. be a SOCK_SEQPACKET or
+// SOCK_DGRAM socket, else unix.EPROTOTYPE is returned.
+//
+// The line request is passed as a file descriptor, so the lines remain
+// requested throughout, and output lines retain their values.  This allows a
+// process to be replaced without the outputs reverting.
+//
+// Once exported the request is closed in this process, but without applying
+// any release state, so the lines are only released when the importing
+// process closes the request.  Any edge events read before the request is
+// sent are passed to the event handler in this process, while subsequent
+// events are available to the importing process.  As for Close, Export waits
+// for any running event handler to return, so must not be called from the
+// context of the event handler.  If the export fails then the request remains
+// open in this process.
+//
+// Sharded requests cannot be exported, and return ErrSharded, nor can uAPI v1
+// requests with edge detection.
+func (l *baseLine) Export(conn *net.UnixConn) error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ErrClosed
+	}
+	if l.shards != nil {
+		l.mu.Unlock()
+		return ErrSharded
+	}
+	if l.isEvent {
+		l.mu.Unlock()
+		return ErrUapiIncompatibility{"exporting edge detection", 1}
+	}
+	er := exportedRequest{
+		Chip:       l.chip,
+		ABI:        l.abi,
+		Offsets:    l.offsets,
+		Values:     l.values,
+		Config:     l.defCfg,
+		LineConfig: l.lineCfg,
+	}
+	data, err := json.Marshal(er)
+	if err == nil && len(data) > maxExportSize {
+		err = unix.EMSGSIZE
+	}
+	if err == nil {
+		err = checkSocketType(conn)
+	}
+	if err == nil {
+		err = l.sendRequest(conn, data)
+	}
+	if err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	// the request now belongs to the importing process, so is closed before
+	// the lock is released.
+	if l.lease != nil {
+		l.lease.end()
+	}
+	if l.releaseState != nil {
+		unregisterReleaser(l)
+		l.releaseState = nil
+	}
+	l.close()
+	l.mu.Unlock()
+	return nil
+}
+
+// sendRequest sends the encoded state and file descriptor of the line
+// request over the socket.
+//
+// Any watcher stops reading events while the request is sent, and the fd is
+// made blocking, as expected by an importer reading events directly.  If the
+// send fails the watcher resumes.
+//
+// The caller must hold the lock.
+func (l *baseLine) sendRequest(conn *net.UnixConn, data []byte) error {
+	w, _ := l.watcher.(*watcher)
+	if w != nil {
+		w.pause()
+	}
+	err := unix.SetNonblock(int(l.vfd), false)
+	if err == nil {
+		msg := make([]byte, 4, 4+len(data))
+		binary.LittleEndian.PutUint32(msg, uint32(len(data)))
+		msg = append(msg, data...)
+		_, _, err = conn.WriteMsgUnix(msg, unix.UnixRights(int(l.vfd)), nil)
+	}
+	if err != nil && w != nil {
+		unix.SetNonblock(int(l.vfd), true)
+		w.resume()
+	}
+	return err
+}
+
+// ImportLine receives a single line request exported by Line.Export from
+// another process over the Unix domain socket.
+//
+// The request is imported as per ImportLines.  If the request contains more
+// than one line then ErrInvalidOffset is returned, and the request is left
+// on the socket, still requested, so it may be imported by ImportLines.
+func ImportLine(conn *net.UnixConn, options ...LineReqOption) (*Line, error) {
+	l := Line{}
+	single := func(er exportedRequest) error {
+		if len(er.Offsets) != 1 {
+			return ErrInvalidOffset
+		}
+		return nil
+	}
+	if err := importLines(&l.baseLine, conn, single, options); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ImportLines receives a line request exported by Line.Export or
+// Lines.Export from another process over the Unix domain socket.
+//
+// The request retains the offsets, configuration and values of the exported
+// request, and the kernel request is not altered, so output lines are
+// undisturbed.
+//
+// The options provide the remaining request options, such as event handlers,
+// release state and constraints, with any line configuration options being
+// ignored.  Use Reconfigure to subsequently alter the configuration.
+//
+// The socket must preserve message boundaries, as per Export.
+func ImportLines(conn *net.UnixConn, options ...LineReqOption) (*Lines, error) {
+	l := Lines{}
+	if err := importLines(&l.baseLine, conn, nil, options); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func importLines(l *baseLine, conn *net.UnixConn, check func(exportedRequest) error, options []LineReqOption) error {
+	fd, er, err := receiveRequest(conn, check)
+	if err != nil {
+		return err
+	}
+	c, err := NewChip(er.Chip, WithABIVersion(er.ABI))
+	if err != nil {
+		unix.Close(fd)
+		return err
+	}
+	defer c.Close()
+	lro := c.newLineReqOptions(er.Offsets, options)
+	// the configuration is that of the kernel request.
+	lro.offsets = er.Offsets
+	lro.values = er.Values
+	lro.defCfg = er.Config
+	lro.lineCfg = er.LineConfig
+	if lro.values == nil {
+		lro.values = map[int]int{}
+	}
+	lro.abi = er.ABI
+	lro.shard = false
+	lro.waitCtx = nil
+	lro.imported = true
+	lro.fd = int32(fd)
+	// the fd is blocking unless, and until, it is watched.
+	if err = unix.SetNonblock(fd, false); err != nil {
+		unix.Close(fd)
+		return err
+	}
+	err = c.requestWithOptions(l, lro)
+	if err != nil && l.vfd != uintptr(fd) {
+		// the fd was not adopted by the request.
+		unix.Close(fd)
+	}
+	return err
+}
+
+// receiveRequest receives the file descriptor and state of an exported line
+// request.
+//
+// If check is provided then the request is first peeked at, and is only
+// removed from the socket if check accepts it.
+func receiveRequest(conn *net.UnixConn, check func(exportedRequest) error) (int, exportedRequest, error) {
+	if err := checkSocketType(conn); err != nil {
+		return -1, exportedRequest{}, err
+	}
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return -1, exportedRequest{}, err
+	}
+	if check != nil {
+		fd, er, err := readRequest(rc, unix.MSG_PEEK)
+		if err != nil {
+			return -1, er, err
+		}
+		// the peeked fd is a duplicate of the one left on the socket.
+		unix.Close(fd)
+		if err = check(er); err != nil {
+			return -1, er, err
+		}
+	}
+	fd, er, err := readRequest(rc, 0)
+	if err == nil && check != nil {
+		// in case the socket is being read concurrently
+		if err = check(er); err != nil {
+			unix.Close(fd)
+			return -1, er, err
+		}
+	}
+	return fd, er, err
+}
+
+// readRequest reads a message containing an exported line request from the
+// socket.
+func readRequest(rc syscall.RawConn, flags int) (int, exportedRequest, error) {
+	var er exportedRequest
+	buf := make([]byte, 4+maxExportSize)
+	oob := make([]byte, unix.CmsgSpace(4*maxReceivedFds))
+	var n, oobn, rflags int
+	var rerr error
+	err := rc.Read(func(s uintptr) bool {
+		n, oobn, rflags, _, rerr = unix.Recvmsg(int(s), buf, oob, flags|unix.MSG_CMSG_CLOEXEC)
+		return rerr != unix.EAGAIN
+	})
+	if err == nil {
+		err = rerr
+	}
+	if err != nil {
+		return -1, er, err
+	}
+	if n == 0 && oobn == 0 {
+		return -1, er, io.EOF
+	}
+	fd, err := receivedFd(oob[:oobn])
+	if err != nil {
+		return -1, er, err
+	}
+	if rflags&(unix.MSG_TRUNC|unix.MSG_CTRUNC) != 0 {
+		err = unix.EMSGSIZE
+	} else if n < 4 || n != 4+int(binary.LittleEndian.Uint32(buf)) {
+		err = unix.EBADMSG
+	} else {
+		err = json.Unmarshal(buf[4:n], &er)
+	}
+	if err != nil {
+		unix.Close(fd)
+		return -1, er, err
+	}
+	return fd, er, nil
+}
+
+// receivedFd returns the single file descriptor received in the control
+// messages.
+//
+// Returns unix.EBADMSG, and closes any received file descriptors, if the
+// control messages do not contain exactly one file descriptor.
+func receivedFd(oob []byte) (int, error) {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	var fds []int
+	for i := range msgs {
+		if rights, rerr := unix.ParseUnixRights(&msgs[i]); rerr == nil {
+			fds = append(fds, rights...)
+		}
+	}
+	if err == nil && len(fds) == 1 {
+		return fds[0], nil
+	}
+	for _, fd := range fds {
+		unix.Close(fd)
+	}
+	if err == nil {
+		err = unix.EBADMSG
+	}
+	return -1, err
+}
+
+// checkSocketType returns unix.EPROTOTYPE if the socket does not preserve
+// message boundaries, so an exported request could be split or merged with
+// another.
+func checkSocketType(conn *net.UnixConn) error {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var typ int
+	var serr error
+	err = rc.Control(func(s uintptr) {
+		typ, serr = unix.GetsockoptInt(int(s), unix.SOL_SOCKET, unix.SO_TYPE)
+	})
+	if err == nil {
+		err = serr
+	}
+	if err != nil {
+		return err
+	}
+	if typ != unix.SOCK_SEQPACKET && typ != unix.SOCK_DGRAM {
+		return unix.EPROTOTYPE
+	}
+	return nil
+}
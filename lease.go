@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"time"
+)
+
+// Lease is a time limited setting of the values of output lines, which
+// reverts the lines to a safe state unless the lease is renewed before it
+// expires.
+//
+// This acts as a dead-man switch, protecting hardware such as pumps and
+// heaters if the controlling goroutine hangs.
+//
+// On expiry the release state is applied to the lines if they were requested
+// WithReleaseState, regardless of any constraints, else the lines are set
+// inactive.  If setting the lines inactive would violate a constraint then the
+// expiry is retried once the constraint permits.
+type Lease struct {
+	l   *baseLine
+	ttl time.Duration
+
+	// closed when the lease expires.
+	done chan struct{}
+
+	// covered by the lock of the request.
+	timer *time.Timer
+	gen   int
+	ended bool
+	err   error
+}
+
+// SetValueFor sets the current active state of the line, and leases that
+// state for the ttl.
+//
+// Only valid for output lines.
+//
+// Any existing lease on the line is ended, without reverting the line.
+func (l *Line) SetValueFor(value int, ttl time.Duration) (*Lease, error) {
+	return l.setValuesFor([]int{value}, ttl)
+}
+
+// SetValuesFor sets the current active state of the collection of lines, as
+// per SetValues, and leases that state for the ttl.
+//
+// Only valid for output lines.
+//
+// Any existing lease on the lines is ended, without reverting the lines.
+func (l *Lines) SetValuesFor(values []int, ttl time.Duration) (*Lease, error) {
+	return l.setValuesFor(values, ttl)
+}
+
+func (l *baseLine) setValuesFor(values []int, ttl time.Duration) (*Lease, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.defCfg.Direction != LineDirectionOutput {
+		return nil, ErrPermissionDenied
+	}
+	if l.closed {
+		return nil, ErrClosed
+	}
+	if err := l.constraints.check(l.valuesMap(values)); err != nil {
+		return nil, err
+	}
+	if err := l.setValues(values); err != nil {
+		return nil, err
+	}
+	if l.lease != nil {
+		l.lease.end()
+	}
+	ls := &Lease{l: l, ttl: ttl, done: make(chan struct{})}
+	ls.start()
+	l.lease = ls
+	return ls, nil
+}
+
+// Renew extends the lease to the ttl from now.
+//
+// Returns ErrLeaseExpired if the lease has already ended.
+func (ls *Lease) Renew() error {
+	ls.l.mu.Lock()
+	defer ls.l.mu.Unlock()
+	if ls.ended {
+		return ErrLeaseExpired
+	}
+	ls.timer.Stop()
+	ls.start()
+	return nil
+}
+
+// Cancel ends the lease, leaving the lines in their current state.
+//
+// Returns ErrLeaseExpired if the lease has already ended.
+func (ls *Lease) Cancel() error {
+	ls.l.mu.Lock()
+	defer ls.l.mu.Unlock()
+	if ls.ended {
+		return ErrLeaseExpired
+	}
+	ls.end()
+	ls.l.lease = nil
+	return nil
+}
+
+// Done returns a channel that is closed when the lease expires and the lines
+// have been reverted.
+//
+// The channel is not closed if the lease ends without expiring.
+func (ls *Lease) Done() <-chan struct{} {
+	return ls.done
+}
+
+// Err returns the error from reverting the lines when the lease expired, if
+// any.
+func (ls *Lease) Err() error {
+	ls.l.mu.Lock()
+	defer ls.l.mu.Unlock()
+	return ls.err
+}
+
+// start starts the timer for the lease.
+//
+// The caller must hold the lock of the request.
+func (ls *Lease) start() {
+	ls.gen++
+	gen := ls.gen
+	ls.timer = time.AfterFunc(ls.ttl, func() {
+		ls.expire(gen)
+	})
+}
+
+// end ends the lease without reverting the lines.
+//
+// The caller must hold the lock of the request.
+func (ls *Lease) end() {
+	ls.timer.Stop()
+	ls.ended = true
+}
+
+// expire reverts the lines, unless the lease has since been renewed or ended.
+func (ls *Lease) expire(gen int) {
+	l := ls.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ls.ended || ls.gen != gen {
+		return
+	}
+	var err error
+	if l.releaseState != nil {
+		err = l.release()
+	} else if err = l.constraints.check(l.valuesMap(nil)); err == nil {
+		err = l.setValues(nil)
+	}
+	if cv, ok := err.(ErrConstraintViolation); ok && cv.RetryAfter > 0 {
+		// retry once the constraint permits the lines to be reverted.
+		ls.timer = time.AfterFunc(cv.RetryAfter, func() {
+			ls.expire(gen)
+		})
+		return
+	}
+	ls.err = err
+	ls.ended = true
+	l.lease = nil
+	close(ls.done)
+}
@@ -5,6 +5,7 @@
 package gpiod
 
 import (
+	"context"
 	"time"
 
 	"github.com/taemon1337/gpiod/uapi"
@@ -66,7 +67,25 @@ type lineReqOptions struct {
 	consumer        string
 	abi             int
 	eh              EventHandler
+	ech             *EventChannelOption
+	geh             GroupEventHandler
+	oh              OverflowHandler
 	eventBufferSize int
+	shard           bool
+	pollPeriod      time.Duration
+	softDebounce    bool
+	queueSize       int
+	queuePolicy     EventQueuePolicy
+	workers         int
+	initialState    bool
+	waitCtx         context.Context
+	releaseState    []LineConfigOption
+	constraints     []Constraint
+
+	// the fd of an imported line request, which is adopted rather than
+	// requested from the kernel.
+	imported bool
+	fd       int32
 }
 
 // lineConfigOptions contains the configuration options for a Line(s) reconfigure.
@@ -75,6 +94,9 @@ type lineConfigOptions struct {
 	values  map[int]int
 	defCfg  LineConfig
 	lineCfg map[int]*LineConfig
+
+	// changes to the per-line event handlers, with nil removing the handler.
+	handlers map[int]EventHandler
 }
 
 func (lco *lineConfigOptions) lineConfig(offset int) *LineConfig {
@@ -90,6 +112,59 @@ func (lco *lineConfigOptions) lineConfig(offset int) *LineConfig {
 	return lc
 }
 
+// config returns the effective configuration of the line at the offset,
+// without branching it from the default configuration.
+func (lco lineConfigOptions) config(offset int) LineConfig {
+	if plc := lco.lineCfg[offset]; plc != nil {
+		return *plc
+	}
+	return lco.defCfg
+}
+
+// subset returns the configuration for a subset of the lines, identified by
+// their keys, re-keyed to the corresponding offsets.
+func (lco lineConfigOptions) subset(keys []int, offsets []int) lineConfigOptions {
+	sub := lineConfigOptions{
+		offsets: offsets,
+		values:  map[int]int{},
+		defCfg:  lco.defCfg,
+	}
+	for i, key := range keys {
+		offset := offsets[i]
+		if v, ok := lco.values[key]; ok {
+			sub.values[offset] = v
+		}
+		if lc := lco.lineCfg[key]; lc != nil {
+			if sub.lineCfg == nil {
+				sub.lineCfg = map[int]*LineConfig{}
+			}
+			tlc := *lc
+			sub.lineCfg[offset] = &tlc
+		}
+		if eh, ok := lco.handlers[key]; ok {
+			if sub.handlers == nil {
+				sub.handlers = map[int]EventHandler{}
+			}
+			sub.handlers[offset] = eh
+		}
+	}
+	return sub
+}
+
+// drivenValues returns the values the configuration drives the lines to,
+// keyed by offset, with lines that are not outputs being inactive.
+func (lco lineConfigOptions) drivenValues() map[int]int {
+	vv := make(map[int]int, len(lco.offsets))
+	for _, offset := range lco.offsets {
+		if lco.config(offset).Direction == LineDirectionOutput {
+			vv[offset] = lco.values[offset]
+		} else {
+			vv[offset] = 0
+		}
+	}
+	return vv
+}
+
 func (lco lineConfigOptions) outputValues() uapi.OutputValues {
 	ov := uapi.LineBitmap(0)
 	for idx, val := range lco.offsets {
@@ -397,6 +472,17 @@ func (o EventHandler) applyChipOption(c *ChipOptions) {
 
 func (o EventHandler) applyLineReqOption(lro *lineReqOptions) {
 	lro.eh = o
+	lro.ech = nil
+	lro.geh = nil
+}
+
+func (o EventHandler) applySubsetLineConfigOption(offsets []int, lco *lineConfigOptions) {
+	if lco.handlers == nil {
+		lco.handlers = map[int]EventHandler{}
+	}
+	for _, offset := range offsets {
+		lco.handlers[offset] = o
+	}
 }
 
 // WithEventHandler indicates that a line will generate events when its active
@@ -412,10 +498,118 @@ func (o EventHandler) applyLineReqOption(lro *lineReqOptions) {
 // Note that calling Close on the requested line from within the event handler
 // will result in deadlock, as the Close waits for the event handler to
 // return.  Therefore the Close must be called from a different goroutine.
+//
+// When applied to a subset of lines using WithLines, the handler only receives
+// the events from those lines, in place of the handler for the request.
+// Per-line handlers may be added, replaced, or removed with a nil handler,
+// using Reconfigure or Lines.SetLineEventHandler.
 func WithEventHandler(e EventHandler) EventHandler {
 	return e
 }
 
+// EventChannelOption indicates that events are to be delivered to a channel
+// rather than to an EventHandler.
+type EventChannelOption struct {
+	ctx context.Context
+	ch  chan<- LineEvent
+}
+
+func (o EventChannelOption) applyLineReqOption(lro *lineReqOptions) {
+	if o.ch == nil {
+		return
+	}
+	lro.eh = nil
+	lro.ech = &o
+	lro.geh = nil
+}
+
+// WithEventChannel indicates that edge events detected on the requested lines
+// are to be sent to the provided channel.
+//
+// This is an alternative to WithEventHandler, and overrides any previous
+// EventHandler option, including any event handler set for the chip.
+//
+// Events are sent serially, in the order they are received from the kernel.
+// The send blocks until the event is received from the channel, so the
+// channel should be read promptly or be buffered to minimize the possibility
+// of overflowing the queue of events in the kernel.
+//
+// The channel is closed once the requested lines are closed or the ctx is
+// done, whichever comes first.  Events detected after the ctx is done are
+// discarded.  Unlike with an EventHandler, Close may be called while a send
+// to the channel is pending.
+//
+// A nil channel is ignored, leaving any previous EventHandler option in
+// place.
+func WithEventChannel(ctx context.Context, ch chan<- LineEvent) EventChannelOption {
+	return EventChannelOption{ctx: ctx, ch: ch}
+}
+
+// GroupEventHandler is a receiver for line events that identifies the line
+// by its position in the request.
+type GroupEventHandler func(GroupEvent)
+
+func (o GroupEventHandler) applyLineReqOption(lro *lineReqOptions) {
+	lro.eh = nil
+	lro.ech = nil
+	lro.geh = o
+}
+
+// forLines returns an EventHandler that forwards events from the lines on
+// the chip to the GroupEventHandler.
+func (o GroupEventHandler) forLines(chip string, offsets []int) EventHandler {
+	lines := make(map[int]int, len(offsets))
+	for i, offset := range offsets {
+		lines[offset] = i
+	}
+	return func(evt LineEvent) {
+		o(GroupEvent{Line: lines[evt.Offset], Chip: chip, LineEvent: evt})
+	}
+}
+
+// WithGroupEventHandler indicates that edge events detected on the requested
+// lines are to be forwarded to the provided handler as GroupEvents, which
+// identify the chip of the line and the position of the line in the request.
+//
+// This is an alternative to WithEventHandler and is primarily intended for
+// use with RequestGroup, where lines from different chips may share an
+// offset, but may also be used with RequestLines.
+//
+// The same restrictions apply as for WithEventHandler.
+func WithGroupEventHandler(eh GroupEventHandler) GroupEventHandler {
+	return eh
+}
+
+// OverflowHandler is a receiver for event overflow notifications.
+type OverflowHandler func(LineEventOverflow)
+
+func (o OverflowHandler) applyLineReqOption(lro *lineReqOptions) {
+	lro.oh = o
+}
+
+// WithOverflowHandler provides a handler to be called when edge events from
+// the requested lines are found to have been discarded by the kernel.
+//
+// The kernel discards the oldest events when its event buffer overflows.
+// The loss is detected from gaps in the event seqnos, so the handler is
+// called when the first event after the gap is received, and before that
+// event is passed to the event handler or channel, or returned by ReadEvent.
+//
+// As the discarded events may include state changes, the consumer may need to
+// resynchronise by reading the current line values.
+//
+// The handler is called from the watcher goroutine that reads the events
+// from the kernel, or from the caller of ReadEvent or ReadEvents, and blocks
+// the reading of events, so should return promptly.  This is the goroutine
+// that calls the event handler, unless WithEventQueue or WithEventWorkers is
+// used, in which case the event handler is called from the queue workers and
+// may run concurrently with the overflow handler.
+//
+// Requires uAPI v2.
+func WithOverflowHandler(h OverflowHandler) OverflowHandler {
+	return h
+}
+
 func (o LineEdge) applyLineConfig(lc *LineConfig) {
 	lc.EdgeDetection = o
 	lc.Direction = LineDirectionInput
@@ -506,6 +700,17 @@ const WithMonotonicEventClock = LineEventClockMonotonic
 // Requires Linux v5.11 or later.
 const WithRealtimeEventClock = LineEventClockRealtime
 
+// WithHTEEventClock specifies that the edge event timestamps are sourced
+// from the hardware timestamp engine (HTE).
+//
+// The HTE provides timestamps captured by hardware at the time of the edge,
+// so they are more precise than those captured by the kernel interrupt
+// handler, but requires both the kernel and the chip to support HTE.
+// If either does not then the request fails with an ErrUapiIncompatibility.
+//
+// Requires Linux v5.19 or later.
+const WithHTEEventClock = LineEventClockHTE
+
 // DebounceOption indicates that a line will be debounced.
 //
 // The DebounceOption requires Linux v5.10 or later.
@@ -542,6 +747,119 @@ func WithDebounce(period time.Duration) DebounceOption {
 	return DebounceOption(period)
 }
 
+// MinPulseWidthOption sets the minimum width of active pulses whose edges are
+// reported.
+type MinPulseWidthOption time.Duration
+
+func (o MinPulseWidthOption) applyLineConfig(lc *LineConfig) {
+	lc.MinPulseWidth = time.Duration(o)
+}
+
+func (o MinPulseWidthOption) applyLineReqOption(lro *lineReqOptions) {
+	o.applyLineConfig(&lro.defCfg)
+}
+
+func (o MinPulseWidthOption) applyLineConfigOption(lco *lineConfigOptions) {
+	o.applyLineConfig(&lco.defCfg)
+}
+
+func (o MinPulseWidthOption) applySubsetLineConfigOption(offsets []int, lco *lineConfigOptions) {
+	for _, offset := range offsets {
+		o.applyLineConfig(lco.lineConfig(offset))
+	}
+}
+
+// WithMinPulseWidth indicates that active pulses, from a rising edge to a
+// falling edge, shorter than the width are to be filtered out.
+//
+// Rising edges are held for the width, along with any subsequent events from
+// the request, and are discarded, along with the falling edge, if the falling
+// edge occurs within the width.
+//
+// A zero width disables the filter.
+//
+// Event filters are performed in userspace, and only apply to events
+// delivered to an event handler or channel.
+func WithMinPulseWidth(width time.Duration) MinPulseWidthOption {
+	return MinPulseWidthOption(width)
+}
+
+// EdgePairSuppressionOption sets the window within which pairs of opposite
+// edges are suppressed.
+type EdgePairSuppressionOption time.Duration
+
+func (o EdgePairSuppressionOption) applyLineConfig(lc *LineConfig) {
+	lc.EdgePairWindow = time.Duration(o)
+}
+
+func (o EdgePairSuppressionOption) applyLineReqOption(lro *lineReqOptions) {
+	o.applyLineConfig(&lro.defCfg)
+}
+
+func (o EdgePairSuppressionOption) applyLineConfigOption(lco *lineConfigOptions) {
+	o.applyLineConfig(&lco.defCfg)
+}
+
+func (o EdgePairSuppressionOption) applySubsetLineConfigOption(offsets []int, lco *lineConfigOptions) {
+	for _, offset := range offsets {
+		o.applyLineConfig(lco.lineConfig(offset))
+	}
+}
+
+// WithEdgePairSuppression indicates that pairs of opposite edges, either
+// rising then falling or falling then rising, that occur within the window
+// are to be filtered out, such as when a line glitches and returns to its
+// original state.
+//
+// Edges are held for the window, delaying any subsequent events from the
+// request, and are discarded, along with the opposite edge, if the opposite
+// edge occurs within the window.
+//
+// A zero window disables the filter.
+//
+// Event filters are performed in userspace, and only apply to events
+// delivered to an event handler or channel.
+func WithEdgePairSuppression(window time.Duration) EdgePairSuppressionOption {
+	return EdgePairSuppressionOption(window)
+}
+
+// MaxEventRateOption sets the maximum number of edge events reported per
+// second.
+type MaxEventRateOption int
+
+func (o MaxEventRateOption) applyLineConfig(lc *LineConfig) {
+	lc.MaxEventRate = int(o)
+}
+
+func (o MaxEventRateOption) applyLineReqOption(lro *lineReqOptions) {
+	o.applyLineConfig(&lro.defCfg)
+}
+
+func (o MaxEventRateOption) applyLineConfigOption(lco *lineConfigOptions) {
+	o.applyLineConfig(&lco.defCfg)
+}
+
+func (o MaxEventRateOption) applySubsetLineConfigOption(offsets []int, lco *lineConfigOptions) {
+	for _, offset := range offsets {
+		o.applyLineConfig(lco.lineConfig(offset))
+	}
+}
+
+// WithMaxEventRate indicates that edge events on a line exceeding the rate,
+// in events per second, are to be filtered out.
+//
+// The rate is determined from the event timestamps, so an event is discarded
+// if rate events on the line have already been reported within the second
+// before it.  The rate is applied after any pulse width or edge pair filters.
+//
+// A zero rate disables the filter.
+//
+// Event filters are performed in userspace, and only apply to events
+// delivered to an event handler or channel.
+func WithMaxEventRate(rate int) MaxEventRateOption {
+	return MaxEventRateOption(rate)
+}
+
 // ABIVersionOption selects the version of the GPIO ioctl commands to use.
 //
 // The default is to use the latest version supported by the kernel.
@@ -629,6 +947,166 @@ func (o DefaultedOption) applySubsetLineConfigOption(offsets []int, lco *lineCon
 // WithLines() unchanged.
 const Defaulted = DefaultedOption(0)
 
+// ShardingOption allows a request to be split into several kernel requests.
+type ShardingOption bool
+
+func (o ShardingOption) applyLineReqOption(lro *lineReqOptions) {
+	lro.shard = bool(o)
+}
+
+// WithSharding allows a request that cannot be satisfied by a single kernel
+// request, as it contains more than uapi.LinesMax lines or its configuration
+// is too complex, to be split into several kernel requests, or shards.
+//
+// The shards are presented as a single Lines, with offsets, values and
+// events in the same order as an unsharded request.  Edge events from all
+// shards are merged into timestamp order and delivered serially to the event
+// handler or channel, and the event Seqno is renumbered to cover all the
+// lines in the request.  To allow for the shards being read independently,
+// events are held for 10ms before being delivered, so an event delayed by
+// longer than that may still be delivered out of order.
+//
+// A sharded request is not atomic - values are read and set, and
+// configuration updated, separately for each shard.  A Reconfigure does not
+// re-shard the request, so may fail with ErrConfigOverflow.
+// Edge events cannot be read directly from a sharded request, so an event
+// handler or channel must be used.
+//
+// A request that can be satisfied by a single kernel request is not sharded.
+const WithSharding = ShardingOption(true)
+
+// PolledEdgesOption indicates that edge detection on the requested lines is
+// to be performed by periodically polling the line values, rather than by
+// the kernel.
+type PolledEdgesOption time.Duration
+
+func (o PolledEdgesOption) applyLineReqOption(lro *lineReqOptions) {
+	lro.pollPeriod = time.Duration(o)
+}
+
+// WithPolledEdges indicates that edge detection on the requested lines is to
+// be performed by polling the line values with the given period, rather than
+// by the kernel.
+//
+// This is intended for lines that cannot generate interrupts, such as those
+// on some I2C or SPI GPIO expanders, which the kernel refuses to request with
+// edge detection.
+//
+// The edges are detected by comparing successive samples, so pulses shorter
+// than the period may be missed, and the event timestamps indicate when the
+// change was detected rather than when it occurred.  Otherwise the events are
+// delivered to the event handler or channel as for kernel edge detection.
+//
+// Has no effect unless edge detection is enabled and the events are delivered
+// to an event handler or channel.
+func WithPolledEdges(period time.Duration) PolledEdgesOption {
+	return PolledEdgesOption(period)
+}
+
+// SoftwareDebounceOption indicates that debouncing of edge events is to be
+// performed in userspace rather than by the kernel.
+type SoftwareDebounceOption bool
+
+func (o SoftwareDebounceOption) applyLineReqOption(lro *lineReqOptions) {
+	lro.softDebounce = bool(o)
+}
+
+// WithSoftwareDebounce indicates that debouncing of edge events is to be
+// performed in userspace rather than by the kernel.
+//
+// Only applies to debounced input lines with edge detection enabled, and
+// with the events delivered to an event handler or channel.  The kernel is
+// requested to report both edges on those lines, and an edge is passed to the
+// handler once the line has been stable for the debounce period.
+//
+// Userspace debouncing is used automatically for such lines with uAPI v1,
+// which does not support debounce, or if the kernel rejects the request with
+// debounce.  The LineInfo.Config of those lines reports DebounceEmulated.
+const WithSoftwareDebounce = SoftwareDebounceOption(true)
+
+// WaitForReleaseOption indicates that a request for lines held by another
+// request is to wait for the lines to be released.
+type WaitForReleaseOption struct {
+	ctx context.Context
+}
+
+func (o WaitForReleaseOption) applyLineReqOption(lro *lineReqOptions) {
+	lro.waitCtx = o.ctx
+}
+
+// WithWaitForRelease indicates that a request for lines held by another
+// request, which the kernel rejects with EBUSY, is to wait for the lines to
+// be released and then retry, rather than failing immediately.
+//
+// The lines are watched for release using a separate chip, so the wait does
+// not interfere with any line info watches on the requesting chip.
+//
+// If the ctx is done before the lines are released then the request fails
+// with an ErrLineBusy identifying the busy line and its consumer.
+//
+// Requires Linux v5.7 or later.
+func WithWaitForRelease(ctx context.Context) WaitForReleaseOption {
+	return WaitForReleaseOption{ctx}
+}
+
+// ReleaseStateOption specifies the configuration applied to the requested
+// lines when they are released.
+type ReleaseStateOption []LineConfigOption
+
+func (o ReleaseStateOption) applyLineReqOption(lro *lineReqOptions) {
+	lro.releaseState = o
+}
+
+// WithReleaseState specifies the configuration to be applied to the requested
+// lines when they are released, e.g. to revert outputs to inputs, or to drive
+// them to a safe value.
+//
+// The configuration is applied by Close, as per Reconfigure, before the lines
+// are released.  WithLines may be used to provide different states for
+// different lines.
+//
+// The configuration is also applied by ApplyReleaseStates, and so by the
+// process-wide handler installed by ReleaseOnSignal, and by ReleaseOnPanic.
+//
+// Requires Linux v5.5 or later.
+func WithReleaseState(options ...LineConfigOption) ReleaseStateOption {
+	return ReleaseStateOption(options)
+}
+
+// ConstraintsOption specifies constraints on the values that may be set on
+// the requested output lines.
+type ConstraintsOption []Constraint
+
+func (o ConstraintsOption) applyLineReqOption(lro *lineReqOptions) {
+	lro.constraints = append(lro.constraints, o...)
+}
+
+// WithConstraints specifies constraints, such as MutuallyExclusive, on the
+// values that may be set on the requested output lines.
+//
+// The constraints are checked by SetValue, SetValues, SetValuesSubset,
+// SetValuesMap and the equivalent lease methods, which return an
+// ErrConstraintViolation rather than set values that violate a constraint.
+// Lines are identified by offset, or by position for a Group.
+//
+// The constraints are also checked against the values resulting from
+// Reconfigure, with lines that are not outputs considered inactive.
+//
+// The release state is checked when the lines are requested, and the request
+// fails with an ErrConstraintViolation if applying the release state to the
+// requested configuration would violate a constraint.  The release state is
+// then always applied, by Close, ApplyReleaseStates or lease expiry, even if
+// the lines changed too recently for the constraints, as leaving the lines
+// driven would be less safe.  A lease without a release state that cannot
+// set its lines inactive on expiry without violating a constraint retries once
+// the constraint permits.
+//
+// The request fails with ErrInvalidOffset if a constraint refers to a line
+// not in the request.
+func WithConstraints(cc ...Constraint) ConstraintsOption {
+	return ConstraintsOption(cc)
+}
+
 // EventBufferSizeOption provides a suggested minimum number of events the
 // kernel will buffer for the line request.
 //
@@ -652,3 +1130,78 @@ func (o EventBufferSizeOption) applyLineReqOption(lro *lineReqOptions) {
 func WithEventBufferSize(size int) EventBufferSizeOption {
 	return EventBufferSizeOption(size)
 }
+
+// InitialStateEventsOption indicates that an event reporting the current
+// state of each line is to be generated when edge detection starts.
+type InitialStateEventsOption bool
+
+func (o InitialStateEventsOption) applyLineReqOption(lro *lineReqOptions) {
+	lro.initialState = bool(o)
+}
+
+// WithInitialStateEvents indicates that a LineEventInitialState event,
+// reporting the current state of the line, is to be passed to the event
+// handler for each line with edge detection when the lines are requested,
+// and for lines that have edge detection enabled by Reconfigure.
+//
+// The initial state event for a line precedes any edge events from the line,
+// though an edge occurring as edge detection starts may be reported after an
+// initial state that already reflects it.
+//
+// The initial state events are passed to the event handler or channel from a
+// separate goroutine, and so may arrive after the request or Reconfigure has
+// returned.
+//
+// Has no effect unless the events are delivered to an event handler or
+// channel.
+const WithInitialStateEvents = InitialStateEventsOption(true)
+
+// EventQueueOption specifies a queue between the reading of edge events and
+// the event handler.
+type EventQueueOption struct {
+	size   int
+	policy EventQueuePolicy
+}
+
+func (o EventQueueOption) applyLineReqOption(lro *lineReqOptions) {
+	lro.queueSize = o.size
+	lro.queuePolicy = o.policy
+}
+
+// WithEventQueue indicates that edge events are to be queued for the event
+// handler, rather than the handler being called as the events are read.
+//
+// The handler is called from a separate goroutine, so a slow handler does not
+// delay the reading of events and cause the kernel event buffer to overflow.
+// The policy determines what happens when an event arrives and the queue,
+// which holds up to size events, is full.  Events discarded from the queue
+// are counted in EventStats.Dropped.
+//
+// Has no effect unless the events are delivered to an event handler or
+// channel.
+func WithEventQueue(size int, policy EventQueuePolicy) EventQueueOption {
+	return EventQueueOption{size: size, policy: policy}
+}
+
+// EventWorkersOption specifies the number of goroutines calling the event
+// handler.
+type EventWorkersOption int
+
+func (o EventWorkersOption) applyLineReqOption(lro *lineReqOptions) {
+	lro.workers = int(o)
+}
+
+// WithEventWorkers indicates that edge events are to be queued for, and
+// passed to the event handler by, the given number of goroutines.
+//
+// Each worker has its own queue, of the size and policy provided by
+// WithEventQueue, else a queue of 64 events with the EventQueueBlock policy.
+// All the events for a line are passed to the same worker, so the events for
+// each line are handled in order, but events for different lines may be
+// handled concurrently, so the handler must be safe for concurrent use.
+//
+// Has no effect unless the events are delivered to an event handler or
+// channel.
+func WithEventWorkers(n int) EventWorkersOption {
+	return EventWorkersOption(n)
+}
@@ -5,6 +5,8 @@
 package gpiod_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -734,6 +736,101 @@ func TestWithEventHandler(t *testing.T) {
 	waitNoEvent(t, ich)
 }
 
+func TestWithEventChannel(t *testing.T) {
+	offset := 4
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+
+	s.SetPull(offset, 0)
+
+	// handler option on chip is overridden
+	ich := make(chan gpiod.LineEvent, 3)
+	eh := func(evt gpiod.LineEvent) {
+		ich <- evt
+	}
+	chipOpts := []gpiod.ChipOption{gpiod.WithEventHandler(eh)}
+	if kernelAbiVersion != 0 {
+		chipOpts = append(chipOpts, gpiod.ABIVersionOption(kernelAbiVersion))
+	}
+	c := getChip(t, s.DevPath(), chipOpts...)
+	defer c.Close()
+
+	// closed by line close
+	ech := make(chan gpiod.LineEvent, 3)
+	r, err := c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventChannel(context.Background(), ech))
+	require.Nil(t, err)
+	require.NotNil(t, r)
+	evtSeqno = 0
+	waitNoEvent(t, ech)
+	s.SetPull(offset, 1)
+	waitEvent(t, ech, nextEvent(r, 1))
+	s.SetPull(offset, 0)
+	waitEvent(t, ech, nextEvent(r, 0))
+	waitNoEvent(t, ich)
+	err = r.Close()
+	assert.Nil(t, err)
+	waitClosed(t, ech)
+
+	// closed by line close with a pending send
+	ech = make(chan gpiod.LineEvent)
+	r, err = c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventChannel(context.Background(), ech))
+	require.Nil(t, err)
+	require.NotNil(t, r)
+	s.SetPull(offset, 1)
+	time.Sleep(20 * time.Millisecond)
+	err = r.Close()
+	assert.Nil(t, err)
+	waitClosed(t, ech)
+
+	// nil channel is ignored
+	r, err = c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventChannel(context.Background(), nil))
+	require.Nil(t, err)
+	require.NotNil(t, r)
+	s.SetPull(offset, 0)
+	waitOffsetEvent(t, ich, offset, gpiod.LineEventFallingEdge)
+	err = r.Close()
+	assert.Nil(t, err)
+
+	// closed by context
+	ctx, cancel := context.WithCancel(context.Background())
+	ech = make(chan gpiod.LineEvent, 3)
+	r, err = c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventChannel(ctx, ech))
+	require.Nil(t, err)
+	require.NotNil(t, r)
+	defer r.Close()
+	evtSeqno = 0
+	s.SetPull(offset, 1)
+	waitEvent(t, ech, nextEvent(r, 1))
+	cancel()
+	waitClosed(t, ech)
+	s.SetPull(offset, 0)
+	waitNoEvent(t, ich)
+}
+
+func waitClosed(t *testing.T, ch <-chan gpiod.LineEvent) {
+	t.Helper()
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-time.After(time.Second):
+			assert.Fail(t, "timeout waiting for channel close")
+			return
+		}
+	}
+}
+
 func TestWithFallingEdge(t *testing.T) {
 	offset := 4
 	s, err := gpiosim.NewSimpleton(6)
@@ -928,6 +1025,44 @@ func TestWithRealtimeEventClock(t *testing.T) {
 	assert.False(t, evtTime.After(end))
 }
 
+func TestWithHTEEventClock(t *testing.T) {
+	offset := 3
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	l, err := c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithHTEEventClock)
+	if c.UapiAbiVersion() == 1 {
+		// uapi v2 required for event clock option
+		assert.Equal(t, gpiod.ErrUapiIncompatibility{Feature: "event clock", AbiVersion: 1}, err)
+		assert.Nil(t, l)
+		return
+	}
+	// gpio-sim has no HTE provider
+	assert.Equal(t, gpiod.ErrUapiIncompatibility{Feature: "HTE event clock", AbiVersion: 2}, err)
+	assert.Nil(t, l)
+
+	// realtime reported in info
+	requireKernel(t, eventClockRealtimeKernel)
+	l, err = c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithRealtimeEventClock)
+	require.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	inf, err := l.Info()
+	assert.Nil(t, err)
+	assert.Equal(t, gpiod.LineEventClockRealtime, inf.Config.EventClock)
+
+	// rejected by reconfigure
+	err = l.Reconfigure(gpiod.WithHTEEventClock)
+	assert.Equal(t, gpiod.ErrUapiIncompatibility{Feature: "HTE event clock", AbiVersion: 2}, err)
+}
+
 func waitEvent(t *testing.T, ch <-chan gpiod.LineEvent, xevt gpiod.LineEvent) {
 	t.Helper()
 	select {
@@ -988,6 +1123,254 @@ func TestWithDebounce(t *testing.T) {
 	assert.Equal(t, 10*time.Microsecond, inf.Config.DebouncePeriod)
 }
 
+func TestWithSoftwareDebounce(t *testing.T) {
+	offset := 1
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	ich := make(chan gpiod.LineEvent, 3)
+	l, err := c.RequestLine(offset,
+		gpiod.WithRisingEdge,
+		gpiod.WithDebounce(10*time.Millisecond),
+		gpiod.WithSoftwareDebounce,
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			ich <- evt
+		}))
+	require.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+
+	inf, err := l.Info()
+	assert.Nil(t, err)
+	assert.True(t, inf.Config.Debounced)
+	assert.True(t, inf.Config.DebounceEmulated)
+	assert.Equal(t, 10*time.Millisecond, inf.Config.DebouncePeriod)
+	assert.Equal(t, gpiod.LineEdgeRising, inf.Config.EdgeDetection)
+
+	seqno := uint32(1)
+	if c.UapiAbiVersion() == 1 {
+		seqno = 0
+	}
+
+	// glitch
+	s.SetPull(offset, 1)
+	s.SetPull(offset, 0)
+	waitNoEvent(t, ich)
+
+	// settled
+	s.SetPull(offset, 1)
+	select {
+	case evt := <-ich:
+		assert.Equal(t, offset, evt.Offset)
+		assert.Equal(t, gpiod.LineEventRisingEdge, evt.Type)
+		assert.Equal(t, seqno, evt.Seqno)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+
+	// falling edge not reported
+	s.SetPull(offset, 0)
+	waitNoEvent(t, ich)
+	waitNoEvent(t, ich)
+}
+
+func TestWithMinPulseWidth(t *testing.T) {
+	offset := 2
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	ich := make(chan gpiod.LineEvent, 3)
+	l, err := c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithMinPulseWidth(10*time.Millisecond),
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			ich <- evt
+		}))
+	require.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	evtSeqno = 0
+
+	inf, err := l.Info()
+	assert.Nil(t, err)
+	assert.Equal(t, 10*time.Millisecond, inf.Config.MinPulseWidth)
+
+	// short pulse
+	s.SetPull(offset, 1)
+	s.SetPull(offset, 0)
+	waitNoEvent(t, ich)
+	assert.Equal(t, uint64(2), l.EventStats().Filtered)
+
+	// long pulse
+	s.SetPull(offset, 1)
+	waitEvent(t, ich, nextEvent(l, 1))
+
+	// short inactive pulse
+	s.SetPull(offset, 0)
+	s.SetPull(offset, 1)
+	waitEvent(t, ich, nextEvent(l, 0))
+	waitEvent(t, ich, nextEvent(l, 1))
+	s.SetPull(offset, 0)
+	waitEvent(t, ich, nextEvent(l, 0))
+	assert.Equal(t, uint64(2), l.EventStats().Filtered)
+}
+
+func TestWithMinPulseWidthOrdering(t *testing.T) {
+	offsets := []int{1, 4}
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+	requireABI(t, c, 2)
+
+	ich := make(chan gpiod.LineEvent, 6)
+	l, err := c.RequestLines(offsets,
+		gpiod.WithBothEdges,
+		gpiod.WithLines([]int{offsets[0]},
+			gpiod.WithMinPulseWidth(20*time.Millisecond)),
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			ich <- evt
+		}))
+	require.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+
+	var last gpiod.LineEvent
+	expect := func(offset int, typ gpiod.LineEventType) {
+		t.Helper()
+		select {
+		case evt := <-ich:
+			assert.Equal(t, offset, evt.Offset)
+			assert.Equal(t, typ, evt.Type)
+			assert.Equal(t, last.Seqno+1, evt.Seqno)
+			assert.GreaterOrEqual(t, int64(evt.Timestamp), int64(last.Timestamp))
+			last = evt
+		case <-time.After(time.Second):
+			assert.Fail(t, "timeout waiting for event")
+		}
+	}
+
+	// events on the unfiltered line are held behind the held edge
+	s.SetPull(offsets[0], 1)
+	s.SetPull(offsets[1], 1)
+	s.SetPull(offsets[1], 0)
+	expect(offsets[0], gpiod.LineEventRisingEdge)
+	expect(offsets[1], gpiod.LineEventRisingEdge)
+	expect(offsets[1], gpiod.LineEventFallingEdge)
+	s.SetPull(offsets[0], 0)
+	expect(offsets[0], gpiod.LineEventFallingEdge)
+
+	// and renumbered after the held edge is dropped
+	s.SetPull(offsets[0], 1)
+	s.SetPull(offsets[1], 1)
+	s.SetPull(offsets[0], 0)
+	s.SetPull(offsets[1], 0)
+	expect(offsets[1], gpiod.LineEventRisingEdge)
+	expect(offsets[1], gpiod.LineEventFallingEdge)
+	waitNoEvent(t, ich)
+	assert.Equal(t, uint64(2), l.EventStats().Filtered)
+}
+
+func TestWithEdgePairSuppression(t *testing.T) {
+	offsets := []int{1, 4}
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	s.SetPull(offsets[1], 1)
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+	requireABI(t, c, 2)
+
+	ich := make(chan gpiod.LineEvent, 3)
+	l, err := c.RequestLines(offsets,
+		gpiod.WithBothEdges,
+		gpiod.WithLines([]int{offsets[1]},
+			gpiod.WithEdgePairSuppression(10*time.Millisecond)),
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			ich <- evt
+		}))
+	require.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	evtSeqno = 0
+
+	info, err := l.Info()
+	assert.Nil(t, err)
+	require.Equal(t, 2, len(info))
+	assert.Equal(t, time.Duration(0), info[0].Config.EdgePairWindow)
+	assert.Equal(t, 10*time.Millisecond, info[1].Config.EdgePairWindow)
+
+	// glitch, from either state
+	s.SetPull(offsets[1], 0)
+	s.SetPull(offsets[1], 1)
+	waitNoEvent(t, ich)
+	assert.Equal(t, uint64(2), l.EventStats().Filtered)
+
+	// unfiltered line
+	s.SetPull(offsets[0], 1)
+	s.SetPull(offsets[0], 0)
+	for _, typ := range []gpiod.LineEventType{gpiod.LineEventRisingEdge, gpiod.LineEventFallingEdge} {
+		select {
+		case evt := <-ich:
+			assert.Equal(t, offsets[0], evt.Offset)
+			assert.Equal(t, typ, evt.Type)
+		case <-time.After(time.Second):
+			assert.Fail(t, "timeout waiting for event")
+		}
+	}
+
+	// settled
+	s.SetPull(offsets[1], 0)
+	select {
+	case evt := <-ich:
+		assert.Equal(t, offsets[1], evt.Offset)
+		assert.Equal(t, gpiod.LineEventFallingEdge, evt.Type)
+		// renumbered, so contiguous
+		assert.Equal(t, uint32(3), evt.Seqno)
+		assert.Equal(t, uint32(1), evt.LineSeqno)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+}
+
+func TestWithMaxEventRate(t *testing.T) {
+	offset := 3
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	ich := make(chan gpiod.LineEvent, 5)
+	l, err := c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithMaxEventRate(2),
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			ich <- evt
+		}))
+	require.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	evtSeqno = 0
+
+	s.SetPull(offset, 1)
+	s.SetPull(offset, 0)
+	s.SetPull(offset, 1)
+	s.SetPull(offset, 0)
+	waitEvent(t, ich, nextEvent(l, 1))
+	waitEvent(t, ich, nextEvent(l, 0))
+	waitNoEvent(t, ich)
+	assert.Equal(t, uint64(2), l.EventStats().Filtered)
+	assert.Equal(t, uint64(4), l.EventStats().Events)
+}
+
 func TestWithLines(t *testing.T) {
 	requireKernel(t, uapiV2Kernel)
 	offsets := []int{4, 3, 2, 1, 0}
@@ -1406,19 +1789,238 @@ func TestDefaulted(t *testing.T) {
 	}
 }
 
-func TestWithEventBufferSize(t *testing.T) {
-	requireKernel(t, uapiV2Kernel)
-	offsets := []int{4, 3, 2, 1}
-	offset := offsets[1]
+func TestWithWaitForRelease(t *testing.T) {
+	requireKernel(t, infoWatchKernel)
+	offset := 3
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	// not busy
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	l, err := c.RequestLine(offset,
+		gpiod.WithConsumer("holder"),
+		gpiod.WithWaitForRelease(ctx))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+
+	// timeout
+	tctx, tcancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer tcancel()
+	l2, err := c.RequestLine(offset, gpiod.WithWaitForRelease(tctx))
+	assert.Nil(t, l2)
+	xerr := gpiod.ErrLineBusy{Offset: offset, Consumer: "holder", Err: context.DeadlineExceeded}
+	assert.Equal(t, xerr, err)
+	assert.True(t, errors.Is(err, unix.EBUSY))
+
+	// released
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		l.Close()
+	}()
+	l2, err = c.RequestLine(offset, gpiod.WithWaitForRelease(ctx))
+	assert.Nil(t, err)
+	require.NotNil(t, l2)
+	l2.Close()
+}
+
+func TestWithReleaseState(t *testing.T) {
+	requireKernel(t, setConfigKernel)
+	offsets := []int{2, 3}
 	s, err := gpiosim.NewSimpleton(6)
 	require.Nil(t, err)
 	defer s.Close()
-	s.SetPull(offset, 0)
 	c := getChip(t, s.DevPath())
 	defer c.Close()
+	// per-line config requires uAPI v2
 	requireABI(t, c, 2)
 
-	patterns := []struct {
+	l, err := c.RequestLines(offsets,
+		gpiod.AsOutput(1, 1),
+		gpiod.WithReleaseState(
+			gpiod.AsOutput(0, 0),
+			gpiod.WithLines(offsets[1:], gpiod.AsInput)))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	checkLevels(t, s, offsets, []int{1, 1})
+
+	// not applied on return
+	gpiod.ReleaseOnPanic(func() {})
+	checkLevels(t, s, offsets, []int{1, 1})
+
+	// applied on panic
+	func() {
+		defer func() {
+			r := recover()
+			assert.Equal(t, "test panic", r)
+		}()
+		gpiod.ReleaseOnPanic(func() {
+			panic("test panic")
+		})
+	}()
+	checkLevels(t, s, offsets[:1], []int{0})
+	ii, err := l.Info()
+	assert.Nil(t, err)
+	require.Equal(t, 2, len(ii))
+	assert.Equal(t, gpiod.LineDirectionOutput, ii[0].Config.Direction)
+	assert.Equal(t, gpiod.LineDirectionInput, ii[1].Config.Direction)
+
+	// applied on close
+	err = l.SetValuesSubset(offsets[:1], []int{1})
+	assert.Nil(t, err)
+	checkLevels(t, s, offsets[:1], []int{1})
+	err = l.Close()
+	assert.Nil(t, err)
+
+	// no longer applied once closed
+	l, err = c.RequestLines(offsets, gpiod.AsOutput(1, 1))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	gpiod.ApplyReleaseStates()
+	checkLevels(t, s, offsets, []int{1, 1})
+}
+
+func TestWithConstraints(t *testing.T) {
+	offsets := []int{1, 2, 4}
+	period := 50 * time.Millisecond
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	// invalid
+	l, err := c.RequestLines(offsets,
+		gpiod.AsOutput(),
+		gpiod.WithConstraints(gpiod.MutuallyExclusive(1, 3)))
+	assert.Equal(t, gpiod.ErrInvalidOffset, err)
+	require.Nil(t, l)
+
+	// release state violates constraint
+	l, err = c.RequestLines(offsets,
+		gpiod.AsOutput(),
+		gpiod.WithConstraints(gpiod.MutuallyExclusive(1, 2)),
+		gpiod.WithReleaseState(gpiod.AsOutput(1, 1, 0)))
+	assert.IsType(t, gpiod.ErrConstraintViolation{}, err)
+	require.Nil(t, l)
+
+	l, err = c.RequestLines(offsets,
+		gpiod.AsOutput(),
+		gpiod.WithConstraints(
+			gpiod.MutuallyExclusive(1, 2),
+			gpiod.DeadTime(period, 1, 2),
+			gpiod.MinToggleInterval(period, 4)))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+
+	// mutually exclusive
+	err = l.SetValues([]int{1, 1, 0})
+	var cv gpiod.ErrConstraintViolation
+	require.True(t, errors.As(err, &cv))
+	assert.IsType(t, gpiod.MutuallyExclusiveConstraint{}, cv.Constraint)
+	assert.Equal(t, []int{1, 2}, cv.Lines)
+	checkLevels(t, s, offsets, []int{0, 0, 0})
+	err = l.SetValuesMap(map[int]int{1: 1})
+	assert.Nil(t, err)
+	err = l.SetValuesMap(map[int]int{2: 1})
+	require.True(t, errors.As(err, &cv))
+	assert.Equal(t, []int{1, 2}, cv.Lines)
+	checkLevels(t, s, offsets, []int{1, 0, 0})
+
+	// dead time
+	err = l.SetValuesSubset([]int{1, 2}, []int{0, 1})
+	require.True(t, errors.As(err, &cv))
+	assert.IsType(t, gpiod.DeadTimeConstraint{}, cv.Constraint)
+	assert.Equal(t, period, cv.RetryAfter)
+	err = l.SetValuesMap(map[int]int{1: 0})
+	assert.Nil(t, err)
+	err = l.SetValuesMap(map[int]int{2: 1})
+	require.True(t, errors.As(err, &cv))
+	assert.IsType(t, gpiod.DeadTimeConstraint{}, cv.Constraint)
+	assert.Greater(t, int64(cv.RetryAfter), int64(0))
+	time.Sleep(cv.RetryAfter)
+	err = l.SetValuesMap(map[int]int{2: 1})
+	assert.Nil(t, err)
+	checkLevels(t, s, offsets, []int{0, 1, 0})
+
+	// min toggle interval
+	err = l.SetValuesMap(map[int]int{4: 1})
+	assert.Nil(t, err)
+	err = l.SetValuesMap(map[int]int{4: 0})
+	require.True(t, errors.As(err, &cv))
+	assert.IsType(t, gpiod.MinToggleIntervalConstraint{}, cv.Constraint)
+	assert.Equal(t, []int{4}, cv.Lines)
+	checkLevels(t, s, offsets, []int{0, 1, 1})
+	time.Sleep(cv.RetryAfter)
+	err = l.SetValuesMap(map[int]int{4: 0})
+	assert.Nil(t, err)
+	checkLevels(t, s, offsets, []int{0, 1, 0})
+
+	// reconfigure
+	requireKernel(t, setConfigKernel)
+	err = l.Reconfigure(gpiod.AsOutput(1, 1, 0))
+	require.True(t, errors.As(err, &cv))
+	assert.IsType(t, gpiod.MutuallyExclusiveConstraint{}, cv.Constraint)
+	checkLevels(t, s, offsets, []int{0, 1, 0})
+	vv := make([]int, len(offsets))
+	err = l.Values(vv)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 1, 0}, vv)
+	err = l.Reconfigure(gpiod.AsOutput(0, 1, 0))
+	assert.Nil(t, err)
+
+	// release state applied regardless of constraints
+	roffsets := []int{0, 3, 5}
+	rl, err := c.RequestLines(roffsets,
+		gpiod.AsOutput(),
+		gpiod.WithConstraints(gpiod.MinToggleInterval(time.Hour, 5)),
+		gpiod.WithReleaseState(gpiod.AsOutput(0, 0, 0)))
+	assert.Nil(t, err)
+	require.NotNil(t, rl)
+	err = rl.SetValuesMap(map[int]int{5: 1})
+	assert.Nil(t, err)
+	checkLevels(t, s, roffsets, []int{0, 0, 1})
+	gpiod.ApplyReleaseStates()
+	checkLevels(t, s, roffsets, []int{0, 0, 0})
+	// the release is recorded
+	err = rl.SetValuesMap(map[int]int{5: 1})
+	require.True(t, errors.As(err, &cv))
+	assert.IsType(t, gpiod.MinToggleIntervalConstraint{}, cv.Constraint)
+	err = rl.Close()
+	assert.Nil(t, err)
+
+	rl, err = c.RequestLines(roffsets,
+		gpiod.AsOutput(),
+		gpiod.WithConstraints(gpiod.MinToggleInterval(time.Hour, 5)),
+		gpiod.WithReleaseState(gpiod.AsOutput(0, 0, 0)))
+	assert.Nil(t, err)
+	require.NotNil(t, rl)
+	err = rl.SetValuesMap(map[int]int{5: 1})
+	assert.Nil(t, err)
+	checkLevels(t, s, roffsets, []int{0, 0, 1})
+	err = rl.Close()
+	assert.Nil(t, err)
+	checkLevels(t, s, roffsets, []int{0, 0, 0})
+}
+
+func TestWithEventBufferSize(t *testing.T) {
+	requireKernel(t, uapiV2Kernel)
+	offsets := []int{4, 3, 2, 1}
+	offset := offsets[1]
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	s.SetPull(offset, 0)
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+	requireABI(t, c, 2)
+
+	patterns := []struct {
 		name     string
 		size     int
 		numLines int
@@ -1467,3 +2069,489 @@ func TestWithEventBufferSize(t *testing.T) {
 		}
 	}
 }
+
+func TestWithInitialStateEvents(t *testing.T) {
+	offsets := []int{2, 3, 4}
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	s.SetPull(offsets[0], 1)
+	s.SetPull(offsets[1], 0)
+	s.SetPull(offsets[2], 1)
+	ich := make(chan gpiod.LineEvent, 3)
+	eh := func(evt gpiod.LineEvent) {
+		ich <- evt
+	}
+	l, err := c.RequestLines(offsets,
+		gpiod.WithBothEdges,
+		gpiod.WithLines(offsets[2:], gpiod.WithoutEdges),
+		gpiod.WithEventHandler(eh),
+		gpiod.WithInitialStateEvents)
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+
+	// initial states, only for lines with edges
+	xvals := map[int]int{offsets[0]: 1, offsets[1]: 0}
+	for i := 0; i < len(xvals); i++ {
+		select {
+		case evt := <-ich:
+			assert.Equal(t, gpiod.LineEventInitialState, evt.Type)
+			xv, ok := xvals[evt.Offset]
+			assert.True(t, ok)
+			assert.Equal(t, xv, evt.Value)
+			assert.Zero(t, evt.Seqno)
+		case <-time.After(time.Second):
+			assert.Fail(t, "timeout waiting for event")
+		}
+	}
+	waitNoEvent(t, ich)
+
+	// edges carry the resulting value
+	s.SetPull(offsets[1], 1)
+	select {
+	case evt := <-ich:
+		assert.Equal(t, gpiod.LineEventRisingEdge, evt.Type)
+		assert.Equal(t, 1, evt.Value)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+
+	// edges started by reconfigure
+	err = l.Reconfigure(gpiod.WithLines(offsets[2:], gpiod.WithBothEdges))
+	if c.UapiAbiVersion() == 1 {
+		// uapi v2 required for edge reconfiguration
+		assert.Equal(t, unix.EINVAL, err)
+		return
+	}
+	require.Nil(t, err)
+	select {
+	case evt := <-ich:
+		assert.Equal(t, gpiod.LineEventInitialState, evt.Type)
+		assert.Equal(t, offsets[2], evt.Offset)
+		assert.Equal(t, 1, evt.Value)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+	waitNoEvent(t, ich)
+}
+
+func TestWithInitialStateEventsDelivery(t *testing.T) {
+	offset := 2
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+	s.SetPull(offset, 1)
+
+	// unbuffered channel
+	ech := make(chan gpiod.LineEvent)
+	l, err := c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventChannel(context.Background(), ech),
+		gpiod.WithInitialStateEvents)
+	require.Nil(t, err)
+	require.NotNil(t, l)
+	select {
+	case evt := <-ech:
+		assert.Equal(t, gpiod.LineEventInitialState, evt.Type)
+		assert.Equal(t, 1, evt.Value)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+	l.Close()
+
+	// handler calling the request
+	lch := make(chan *gpiod.Line, 1)
+	vch := make(chan int, 1)
+	eh := func(evt gpiod.LineEvent) {
+		if evt.Type != gpiod.LineEventInitialState {
+			return
+		}
+		l := <-lch
+		lch <- l
+		v, err := l.Value()
+		assert.Nil(t, err)
+		vch <- v
+	}
+	l, err = c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(eh),
+		gpiod.WithInitialStateEvents)
+	require.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+	lch <- l
+	select {
+	case v := <-vch:
+		assert.Equal(t, 1, v)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+
+	if c.UapiAbiVersion() == 1 {
+		// uapi v2 required for edge reconfiguration
+		return
+	}
+	// and from reconfigure
+	err = l.Reconfigure(gpiod.WithoutEdges)
+	require.Nil(t, err)
+	s.SetPull(offset, 0)
+	err = l.Reconfigure(gpiod.WithBothEdges)
+	require.Nil(t, err)
+	select {
+	case v := <-vch:
+		assert.Equal(t, 0, v)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+}
+
+func TestWithEventQueue(t *testing.T) {
+	offset := 3
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	patterns := []struct {
+		name    string
+		policy  gpiod.EventQueuePolicy
+		xevts   []gpiod.LineEventType
+		dropped uint64
+	}{
+		{"block",
+			gpiod.EventQueueBlock,
+			[]gpiod.LineEventType{gpiod.LineEventRisingEdge, gpiod.LineEventFallingEdge, gpiod.LineEventRisingEdge},
+			0,
+		},
+		{"drop oldest",
+			gpiod.EventQueueDropOldest,
+			[]gpiod.LineEventType{gpiod.LineEventRisingEdge, gpiod.LineEventRisingEdge},
+			1,
+		},
+		{"drop newest",
+			gpiod.EventQueueDropNewest,
+			[]gpiod.LineEventType{gpiod.LineEventRisingEdge, gpiod.LineEventFallingEdge},
+			1,
+		},
+	}
+	for _, p := range patterns {
+		tf := func(t *testing.T) {
+			s.SetPull(offset, 0)
+			release := make(chan struct{})
+			ich := make(chan gpiod.LineEvent, 3)
+			eh := func(evt gpiod.LineEvent) {
+				<-release
+				ich <- evt
+			}
+			l, err := c.RequestLine(offset,
+				gpiod.WithBothEdges,
+				gpiod.WithEventHandler(eh),
+				gpiod.WithEventQueue(1, p.policy))
+			assert.Nil(t, err)
+			require.NotNil(t, l)
+			defer l.Close()
+			// the first event is held by the handler, the second fills the
+			// queue, and the third overflows it.
+			s.SetPull(offset, 1)
+			time.Sleep(20 * time.Millisecond)
+			s.SetPull(offset, 0)
+			time.Sleep(20 * time.Millisecond)
+			s.SetPull(offset, 1)
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			for _, xevt := range p.xevts {
+				select {
+				case evt := <-ich:
+					assert.Equal(t, xevt, evt.Type)
+				case <-time.After(time.Second):
+					assert.Fail(t, "timeout waiting for event")
+				}
+			}
+			waitNoEvent(t, ich)
+			es := l.EventStats()
+			assert.Equal(t, uint64(3), es.Events)
+			assert.Equal(t, p.dropped, es.Dropped)
+		}
+		t.Run(p.name, tf)
+	}
+}
+
+func TestWithEventWorkers(t *testing.T) {
+	offsets := []int{2, 3}
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	release := make(chan struct{})
+	ich := make(chan gpiod.LineEvent, 3)
+	eh := func(evt gpiod.LineEvent) {
+		if evt.Offset == offsets[0] {
+			<-release
+		}
+		ich <- evt
+	}
+	l, err := c.RequestLines(offsets,
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(eh),
+		gpiod.WithEventWorkers(2))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+
+	// a blocked handler for one line does not block the other
+	s.SetPull(offsets[0], 1)
+	s.SetPull(offsets[1], 1)
+	waitOffsetEvent(t, ich, offsets[1], gpiod.LineEventRisingEdge)
+	close(release)
+	waitOffsetEvent(t, ich, offsets[0], gpiod.LineEventRisingEdge)
+	waitNoEvent(t, ich)
+}
+
+func TestWithSharding(t *testing.T) {
+	requireKernel(t, uapiV2Kernel)
+	numLines := uapi.LinesMax + 6
+	s, err := gpiosim.NewSimpleton(numLines)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+	requireABI(t, c, 2)
+
+	// reverse order, to check ordering is maintained across shards
+	offsets := make([]int, numLines)
+	values := make([]int, numLines)
+	for i := range offsets {
+		offsets[i] = numLines - 1 - i
+		values[i] = i & 1
+	}
+
+	// too many lines
+	ll, err := c.RequestLines(offsets, gpiod.AsOutput(values...))
+	assert.Equal(t, unix.EINVAL, err)
+	assert.Nil(t, ll)
+
+	ll, err = c.RequestLines(offsets, gpiod.AsOutput(values...), gpiod.WithSharding)
+	assert.Nil(t, err)
+	require.NotNil(t, ll)
+	assert.Equal(t, offsets, ll.Offsets())
+	for i, o := range offsets {
+		v, err := s.Level(o)
+		assert.Nil(t, err)
+		assert.Equal(t, values[i], v, o)
+	}
+	vv := make([]int, numLines)
+	err = ll.Values(vv)
+	assert.Nil(t, err)
+	assert.Equal(t, values, vv)
+	for i := range values {
+		values[i] ^= 1
+	}
+	err = ll.SetValues(values)
+	assert.Nil(t, err)
+	for i, o := range offsets {
+		v, err := s.Level(o)
+		assert.Nil(t, err)
+		assert.Equal(t, values[i], v, o)
+	}
+	info, err := ll.Info()
+	assert.Nil(t, err)
+	require.Equal(t, numLines, len(info))
+	for i, inf := range info {
+		assert.Equal(t, offsets[i], inf.Offset)
+	}
+	assert.Equal(t, ^uintptr(0), ll.Fd())
+	_, err = ll.ReadEvent()
+	assert.Equal(t, gpiod.ErrSharded, err)
+	ll.Close()
+
+	// too many configs
+	var lopts []gpiod.LineReqOption
+	for i, o := range offsets[:12] {
+		lopts = append(lopts, gpiod.WithLines([]int{o},
+			gpiod.WithDebounce(time.Duration(i+1)*time.Millisecond)))
+	}
+	ll, err = c.RequestLines(offsets[:12], lopts...)
+	assert.Equal(t, gpiod.ErrConfigOverflow, err)
+	assert.Nil(t, ll)
+
+	ll, err = c.RequestLines(offsets[:12], append(lopts, gpiod.WithSharding)...)
+	assert.Nil(t, err)
+	require.NotNil(t, ll)
+	info, err = ll.Info()
+	assert.Nil(t, err)
+	require.Equal(t, 12, len(info))
+	for i, inf := range info {
+		assert.Equal(t, offsets[i], inf.Offset)
+		assert.Equal(t, time.Duration(i+1)*time.Millisecond, inf.Config.DebouncePeriod)
+	}
+	ll.Close()
+
+	// events
+	ch := make(chan gpiod.LineEvent, 3)
+	ll, err = c.RequestLines(offsets,
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			ch <- evt
+		}),
+		gpiod.WithSharding)
+	assert.Nil(t, err)
+	require.NotNil(t, ll)
+	s.SetPull(offsets[numLines-1], 1)
+	s.SetPull(offsets[0], 1)
+	for i, o := range []int{offsets[numLines-1], offsets[0]} {
+		select {
+		case evt := <-ch:
+			assert.Equal(t, o, evt.Offset)
+			assert.Equal(t, gpiod.LineEventRisingEdge, evt.Type)
+			assert.Equal(t, uint32(i+1), evt.Seqno)
+			assert.Equal(t, uint32(1), evt.LineSeqno)
+		case <-time.After(time.Second):
+			assert.Fail(t, "timeout waiting for event")
+		}
+	}
+	ll.Close()
+
+	// events merged across shards in timestamp order
+	toggled := []int{offsets[0], offsets[numLines-1], offsets[1], offsets[numLines-2]}
+	for _, o := range toggled {
+		s.SetPull(o, 0)
+	}
+	ch = make(chan gpiod.LineEvent, 20)
+	ll, err = c.RequestLines(offsets,
+		gpiod.WithBothEdges,
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			ch <- evt
+		}),
+		gpiod.WithSharding)
+	assert.Nil(t, err)
+	require.NotNil(t, ll)
+	defer ll.Close()
+	for _, o := range toggled {
+		s.SetPull(o, 1)
+	}
+	var last time.Duration
+	for i := range toggled {
+		select {
+		case evt := <-ch:
+			assert.Equal(t, toggled[i], evt.Offset)
+			assert.Equal(t, uint32(i+1), evt.Seqno)
+			assert.GreaterOrEqual(t, int64(evt.Timestamp), int64(last))
+			last = evt.Timestamp
+		case <-time.After(time.Second):
+			assert.Fail(t, "timeout waiting for event")
+		}
+	}
+}
+
+func TestWithPolledEdges(t *testing.T) {
+	offset := 3
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+
+	ich := make(chan gpiod.LineEvent, 3)
+	l, err := c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithPolledEdges(time.Millisecond),
+		gpiod.WithEventHandler(func(evt gpiod.LineEvent) {
+			ich <- evt
+		}))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+
+	// the kernel does not perform the edge detection
+	info, err := l.Info()
+	assert.Nil(t, err)
+	assert.Equal(t, gpiod.LineEdgeNone, info.Config.EdgeDetection)
+
+	start := time.Duration(time.Now().UnixNano())
+	waitNoEvent(t, ich)
+	s.SetPull(offset, 1)
+	waitEvent(t, ich, gpiod.LineEvent{Type: gpiod.LineEventRisingEdge, Seqno: 1, LineSeqno: 1})
+	s.SetPull(offset, 0)
+	select {
+	case evt := <-ich:
+		assert.Equal(t, offset, evt.Offset)
+		assert.Equal(t, gpiod.LineEventFallingEdge, evt.Type)
+		assert.Equal(t, uint32(2), evt.Seqno)
+		assert.Equal(t, uint32(2), evt.LineSeqno)
+		// monotonic, not realtime
+		assert.Less(t, evt.Timestamp, start)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+	assert.Equal(t, uint64(2), l.EventStats().Events)
+
+	// reconfigure
+	err = l.Reconfigure(gpiod.WithRisingEdge)
+	assert.Nil(t, err)
+	s.SetPull(offset, 1)
+	waitEvent(t, ich, gpiod.LineEvent{Type: gpiod.LineEventRisingEdge, Seqno: 3, LineSeqno: 3})
+	s.SetPull(offset, 0)
+	waitNoEvent(t, ich)
+
+	l.Close()
+	s.SetPull(offset, 1)
+	waitNoEvent(t, ich)
+}
+
+func TestWithOverflowHandler(t *testing.T) {
+	requireKernel(t, uapiV2Kernel)
+	offset := 2
+	s, err := gpiosim.NewSimpleton(6)
+	require.Nil(t, err)
+	defer s.Close()
+	s.SetPull(offset, 0)
+	c := getChip(t, s.DevPath())
+	defer c.Close()
+	requireABI(t, c, 2)
+
+	var ovfs []gpiod.LineEventOverflow
+	l, err := c.RequestLine(offset,
+		gpiod.WithBothEdges,
+		gpiod.WithEventBufferSize(2),
+		gpiod.WithOverflowHandler(func(ovf gpiod.LineEventOverflow) {
+			ovfs = append(ovfs, ovf)
+		}))
+	assert.Nil(t, err)
+	require.NotNil(t, l)
+	defer l.Close()
+
+	// overflow the kernel buffer
+	for i := 0; i < 3; i++ {
+		s.SetPull(offset, 1)
+		s.SetPull(offset, 0)
+	}
+	time.Sleep(20 * time.Millisecond)
+	evts := make([]gpiod.LineEvent, 8)
+	n, err := l.ReadEvents(evts)
+	require.Nil(t, err)
+	require.Equal(t, 2, n)
+	assert.Equal(t, uint32(5), evts[0].Seqno)
+	assert.Equal(t, uint32(6), evts[1].Seqno)
+	require.Equal(t, 1, len(ovfs))
+	assert.Equal(t, offset, ovfs[0].Offset)
+	assert.Equal(t, uint32(4), ovfs[0].Dropped)
+	assert.Equal(t, uint32(4), ovfs[0].LineDropped)
+	assert.Equal(t, evts[0].Timestamp, ovfs[0].Timestamp)
+	assert.Equal(t, gpiod.EventStats{Events: 2, Overflowed: 4}, l.EventStats())
+
+	// no overflow
+	s.SetPull(offset, 1)
+	evt, err := l.ReadEvent()
+	require.Nil(t, err)
+	assert.Equal(t, uint32(7), evt.Seqno)
+	assert.Equal(t, 1, len(ovfs))
+	assert.Equal(t, gpiod.EventStats{Events: 3, Overflowed: 4}, l.EventStats())
+}
@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"sync"
+	"time"
+
+	"github.com/taemon1337/gpiod/uapi"
+	"golang.org/x/sys/unix"
+)
+
+// poller detects edges on requested lines by periodically sampling their
+// values, for lines that cannot generate interrupts and so do not support
+// edge detection in the kernel.
+//
+// The poller takes ownership of the line request fd, and closes it when it is
+// closed.
+type poller struct {
+	fd      uintptr
+	abi     int
+	offsets []int
+	period  time.Duration
+
+	// the handler for detected events
+	eh EventHandler
+
+	// mu covers the line config, which may be altered by Reconfigure.
+	mu       sync.Mutex
+	edges    []LineEdge
+	realtime []bool
+
+	donech chan struct{}
+	wg     sync.WaitGroup
+}
+
+// newPoller creates a poller that samples the lines of the request every
+// period and passes synthesised edge events to eh.
+func newPoller(abi int, period time.Duration, lco lineConfigOptions, eh EventHandler) *poller {
+	p := &poller{
+		abi:     abi,
+		offsets: lco.offsets,
+		period:  period,
+		eh:      eh,
+		donech:  make(chan struct{}),
+	}
+	p.configure(lco)
+	return p
+}
+
+// configure updates the edge detection and event clocks of the lines.
+func (p *poller) configure(lco lineConfigOptions) {
+	edges := make([]LineEdge, len(p.offsets))
+	realtime := make([]bool, len(p.offsets))
+	for i, offset := range p.offsets {
+		lc := lco.config(offset)
+		if lc.Direction == LineDirectionInput {
+			edges[i] = lc.EdgeDetection
+		}
+		realtime[i] = lc.EventClock == LineEventClockRealtime
+	}
+	p.mu.Lock()
+	p.edges = edges
+	p.realtime = realtime
+	p.mu.Unlock()
+}
+
+// start takes the initial sample of the values of the line request fd, from
+// which the edges are subsequently detected, and starts polling.
+func (p *poller) start(fd uintptr) error {
+	p.fd = fd
+	values := make([]int, len(p.offsets))
+	if err := p.sample(values); err != nil {
+		return err
+	}
+	p.wg.Add(1)
+	go p.poll(values)
+	return nil
+}
+
+// Close stops the poller and closes the line request fd.
+//
+// Waits for any running event handler to return.
+func (p *poller) Close() error {
+	close(p.donech)
+	p.wg.Wait()
+	return unix.Close(int(p.fd))
+}
+
+func (p *poller) poll(values []int) {
+	defer p.wg.Done()
+	t := time.NewTicker(p.period)
+	defer t.Stop()
+	next := make([]int, len(values))
+	var seqno uint32
+	lineSeqno := make([]uint32, len(values))
+	for {
+		select {
+		case <-p.donech:
+			return
+		case <-t.C:
+		}
+		if err := p.sample(next); err != nil {
+			// line request is no longer readable, e.g. the chip has been
+			// removed.
+			return
+		}
+		p.mu.Lock()
+		edges := p.edges
+		realtime := p.realtime
+		p.mu.Unlock()
+		for i, v := range next {
+			if v == values[i] {
+				continue
+			}
+			values[i] = v
+			evt := LineEvent{
+				Offset:    p.offsets[i],
+				Timestamp: timestamp(realtime[i]),
+				Type:      LineEventFallingEdge,
+			}
+			if realtime[i] {
+				evt.Clock = LineEventClockRealtime
+			}
+			edge := LineEdgeFalling
+			if v != 0 {
+				evt.Type = LineEventRisingEdge
+				evt.Value = 1
+				edge = LineEdgeRising
+			}
+			if edges[i]&edge == 0 {
+				continue
+			}
+			seqno++
+			lineSeqno[i]++
+			evt.Seqno = seqno
+			evt.LineSeqno = lineSeqno[i]
+			p.eh(evt)
+		}
+	}
+}
+
+// sample reads the current values of the lines.
+func (p *poller) sample(values []int) error {
+	if p.abi == 1 {
+		hd := uapi.HandleData{}
+		if err := uapi.GetLineValues(p.fd, &hd); err != nil {
+			return err
+		}
+		for i := range values {
+			values[i] = int(hd[i])
+		}
+		return nil
+	}
+	lv := uapi.LineValues{Mask: uapi.NewLineBitMask(len(values))}
+	if err := uapi.GetLineValuesV2(p.fd, &lv); err != nil {
+		return err
+	}
+	for i := range values {
+		values[i] = lv.Get(i)
+	}
+	return nil
+}
+
+// timestamp returns the current time from the clock the kernel would use for
+// edge event timestamps.
+func timestamp(realtime bool) time.Duration {
+	clock := unix.CLOCK_MONOTONIC
+	if realtime {
+		clock = unix.CLOCK_REALTIME
+	}
+	var ts unix.Timespec
+	unix.ClockGettime(int32(clock), &ts)
+	return time.Duration(ts.Nano())
+}
+
+// withoutEdges returns a copy of the config with edge detection disabled, for
+// requesting lines that are to be polled from the kernel.
+func (lco lineConfigOptions) withoutEdges() lineConfigOptions {
+	lco.defCfg.EdgeDetection = LineEdgeNone
+	lco.defCfg.EventClock = LineEventClockMonotonic
+	if lco.lineCfg != nil {
+		lineCfg := make(map[int]*LineConfig, len(lco.lineCfg))
+		for offset, plc := range lco.lineCfg {
+			lc := *plc
+			lc.EdgeDetection = LineEdgeNone
+			lc.EventClock = LineEventClockMonotonic
+			lineCfg[offset] = &lc
+		}
+		lco.lineCfg = lineCfg
+	}
+	return lco
+}
+
+// hasEdges returns true if edge detection is enabled on any of the lines.
+func (lco lineConfigOptions) hasEdges() bool {
+	for _, offset := range lco.offsets {
+		if lco.config(offset).EdgeDetection != LineEdgeNone {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"sync"
+)
+
+// EventQueuePolicy determines the behaviour of an event queue when an event
+// arrives and the queue is full.
+type EventQueuePolicy int
+
+const (
+	// EventQueueBlock indicates that reading events waits until there is
+	// space in the queue, as per an unqueued event handler.
+	//
+	// This is the default policy.
+	EventQueueBlock EventQueuePolicy = iota
+
+	// EventQueueDropOldest indicates that the oldest queued event is
+	// discarded to make space for the new event.
+	EventQueueDropOldest
+
+	// EventQueueDropNewest indicates that the new event is discarded.
+	EventQueueDropNewest
+)
+
+// defaultEventQueueSize is the size of the queue used when workers are
+// requested without a queue size.
+const defaultEventQueueSize = 64
+
+// eventQueue decouples calls to the event handler from the reading of the
+// events, so a slow handler does not stall the reading of events and result
+// in the kernel event buffer overflowing.
+//
+// Each worker has its own queue, and the events for a line are always passed
+// to the same worker, so events for each line are handled in order.
+type eventQueue struct {
+	eh     EventHandler
+	size   int
+	policy EventQueuePolicy
+
+	// mu covers all that follow.
+	mu      sync.Mutex
+	workers []*queueWorker
+	dropped uint64
+	closed  bool
+
+	// signalled when events are removed from a queue.
+	space *sync.Cond
+
+	// the goroutines running the workers.
+	wg sync.WaitGroup
+}
+
+// queueWorker is the queue of a single worker.
+type queueWorker struct {
+	evts []LineEvent
+
+	// signalled when events are added to the queue.
+	ready *sync.Cond
+}
+
+// newEventQueue creates an eventQueue that passes events to eh from the
+// given number of workers, each with a queue of the given size.
+func newEventQueue(size, workers int, policy EventQueuePolicy, eh EventHandler) *eventQueue {
+	if size < 1 {
+		size = defaultEventQueueSize
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	q := &eventQueue{
+		eh:      eh,
+		size:    size,
+		policy:  policy,
+		workers: make([]*queueWorker, workers),
+	}
+	q.space = sync.NewCond(&q.mu)
+	for i := range q.workers {
+		w := &queueWorker{
+			evts:  make([]LineEvent, 0, size),
+			ready: sync.NewCond(&q.mu),
+		}
+		q.workers[i] = w
+		q.wg.Add(1)
+		go q.run(w)
+	}
+	return q
+}
+
+// handle is the EventHandler that adds events to the queue.
+func (q *eventQueue) handle(evt LineEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	w := q.workers[evt.Offset%len(q.workers)]
+	for !q.closed && len(w.evts) >= q.size {
+		switch q.policy {
+		case EventQueueDropNewest:
+			q.dropped++
+			return
+		case EventQueueDropOldest:
+			w.evts = append(w.evts[:0], w.evts[1:]...)
+			q.dropped++
+		default:
+			q.space.Wait()
+		}
+	}
+	if q.closed {
+		return
+	}
+	w.evts = append(w.evts, evt)
+	w.ready.Signal()
+}
+
+// run passes the events in the worker's queue to the event handler, until
+// the queue is stopped.
+func (q *eventQueue) run(w *queueWorker) {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		for !q.closed && len(w.evts) == 0 {
+			w.ready.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		evt := w.evts[0]
+		w.evts = append(w.evts[:0], w.evts[1:]...)
+		q.space.Broadcast()
+		q.mu.Unlock()
+		q.eh(evt)
+	}
+}
+
+// stats returns the number of events dropped from the queue.
+func (q *eventQueue) stats() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// stop unblocks any pending addition to the queue, discards any queued
+// events, and prevents further events being queued.
+func (q *eventQueue) stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	for _, w := range q.workers {
+		w.evts = nil
+		w.ready.Signal()
+	}
+	q.space.Broadcast()
+}
+
+// Close stops the queue.
+//
+// Waits for any running event handlers to return.
+func (q *eventQueue) Close() error {
+	q.stop()
+	q.wg.Wait()
+	return nil
+}
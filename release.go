@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// requestOrWait performs the request, waiting for any lines held by other
+// requests to be released if requested by WithWaitForRelease.
+//
+// A request failing as a line is held by another request returns an
+// ErrLineBusy.
+func (c *Chip) requestOrWait(l *baseLine, lro lineReqOptions) error {
+	err := c.request(l, lro)
+	if err != unix.EBUSY {
+		return err
+	}
+	if lro.waitCtx == nil {
+		busy, _ := c.busyLine(lro.offsets)
+		return busy
+	}
+	ctx := lro.waitCtx
+	// the lines are watched using a separate chip so as not to interfere
+	// with any watches on c.
+	wc, err := NewChip(c.Name, WithABIVersion(c.options.abi))
+	if err != nil {
+		return err
+	}
+	defer wc.Close()
+	released := make(chan struct{}, 1)
+	for _, offset := range lro.offsets {
+		_, err := wc.WatchLineInfo(offset, func(evt LineInfoChangeEvent) {
+			if evt.Type == LineReleased {
+				select {
+				case released <- struct{}{}:
+				default:
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		// retry now the lines are watched, in case they were released before
+		// the watch started.
+		*l = baseLine{}
+		err = c.request(l, lro)
+		if err != unix.EBUSY {
+			return err
+		}
+		busy, found := wc.busyLine(lro.offsets)
+		var retry <-chan time.Time
+		if !found {
+			// the line was released since the request, or is busy for
+			// reasons not visible in the line info.
+			retry = time.After(waitPollPeriod)
+		}
+		select {
+		case <-released:
+		case <-retry:
+		case <-ctx.Done():
+			busy.Err = ctx.Err()
+			return busy
+		}
+	}
+}
+
+// busyLine returns an ErrLineBusy identifying the first of the lines that is
+// in use, and its consumer, and true, or an ErrLineBusy for the first line,
+// and false, if none of the lines are in use.
+func (c *Chip) busyLine(offsets []int) (ErrLineBusy, bool) {
+	for _, offset := range offsets {
+		if li, err := c.LineInfo(offset); err == nil && li.Used {
+			return ErrLineBusy{Offset: offset, Consumer: li.Consumer, Err: unix.EBUSY}, true
+		}
+	}
+	return ErrLineBusy{Offset: offsets[0], Err: unix.EBUSY}, false
+}
+
+// releaser is a request with a release state.
+type releaser interface {
+	applyReleaseState() error
+}
+
+// releasers are the open requests with release states, which are applied by
+// the process-wide handlers.
+var releasers = struct {
+	mu sync.Mutex
+	rr map[releaser]struct{}
+}{rr: map[releaser]struct{}{}}
+
+func registerReleaser(r releaser) {
+	releasers.mu.Lock()
+	releasers.rr[r] = struct{}{}
+	releasers.mu.Unlock()
+}
+
+func unregisterReleaser(r releaser) {
+	releasers.mu.Lock()
+	delete(releasers.rr, r)
+	releasers.mu.Unlock()
+}
+
+// ApplyReleaseStates applies the release state to all open requests made with
+// WithReleaseState.
+//
+// The requests remain open.  This is intended for handlers that are about to
+// terminate the process without closing the requests.
+func ApplyReleaseStates() {
+	releasers.mu.Lock()
+	rr := make([]releaser, 0, len(releasers.rr))
+	for r := range releasers.rr {
+		rr = append(rr, r)
+	}
+	releasers.mu.Unlock()
+	for _, r := range rr {
+		r.applyReleaseState()
+	}
+}
+
+// ReleaseOnSignal installs a process-wide handler that applies the release
+// states, as per ApplyReleaseStates, when the process receives one of the
+// signals, and then terminates the process by re-raising the signal.
+//
+// If no signals are provided then SIGINT and SIGTERM are handled.
+//
+// The handler takes over the signals - re-raising the signal requires
+// restoring its default behaviour, which also undoes any signal.Notify by the
+// application for that signal, so the process is terminated even if the
+// application is handling the signal, e.g. to shut down gracefully.  Such
+// applications should not use ReleaseOnSignal, but close their requests, or
+// call ApplyReleaseStates, in their own handling of the signal.
+//
+// The returned function removes the handler.
+func ReleaseOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{unix.SIGINT, unix.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		select {
+		case sig := <-ch:
+			ApplyReleaseStates()
+			// restore the default behaviour and let it terminate the process
+			signal.Reset(sig)
+			if s, ok := sig.(unix.Signal); ok {
+				unix.Kill(os.Getpid(), s)
+			}
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// ReleaseOnPanic calls fn, and applies the release states, as per
+// ApplyReleaseStates, if fn panics, or otherwise fails to return, e.g.
+//
+//	gpiod.ReleaseOnPanic(run)
+//
+// The panic is not recovered, so it continues, with its original stack trace,
+// once the release states have been applied.
+func ReleaseOnPanic(fn func()) {
+	returned := false
+	defer func() {
+		if !returned {
+			ApplyReleaseStates()
+		}
+	}()
+	fn()
+	returned = true
+}
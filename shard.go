@@ -0,0 +1,356 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/taemon1337/gpiod/uapi"
+)
+
+// shards partitions the lines of a request into sets that can each be
+// satisfied by a single kernel request.
+//
+// Returns the positions of the lines in each shard, or nil if the request
+// does not need to be sharded.
+func (lro lineReqOptions) shards() [][]int {
+	if len(lro.offsets) <= uapi.LinesMax {
+		if lro.abi == 1 {
+			return nil
+		}
+		if _, err := lro.toULineConfig(); err != ErrConfigOverflow {
+			return nil
+		}
+	}
+	var order []int
+	if lro.abi == 1 {
+		// only the default config is supported, so only the number of lines
+		// matters.
+		order = make([]int, len(lro.offsets))
+		for i := range order {
+			order[i] = i
+		}
+	} else {
+		// pack lines with the same config together, so they share
+		// attributes.
+		var cfgs []LineConfig
+		byCfg := map[LineConfig][]int{}
+		for i, offset := range lro.offsets {
+			lc := lro.config(offset)
+			if _, ok := byCfg[lc]; !ok {
+				cfgs = append(cfgs, lc)
+			}
+			byCfg[lc] = append(byCfg[lc], i)
+		}
+		for _, lc := range cfgs {
+			order = append(order, byCfg[lc]...)
+		}
+	}
+	var shards [][]int
+	var shard []int
+	for _, p := range order {
+		if len(shard) != 0 && !lro.fits(append(shard, p)) {
+			shards = append(shards, shard)
+			shard = nil
+		}
+		shard = append(shard, p)
+	}
+	return append(shards, shard)
+}
+
+// fits returns true if the lines at the given positions can be satisfied by
+// a single kernel request.
+func (lro lineReqOptions) fits(positions []int) bool {
+	if len(positions) > uapi.LinesMax {
+		return false
+	}
+	if lro.abi == 1 {
+		return true
+	}
+	offsets := lro.offsetsAt(positions)
+	_, err := lro.subset(offsets, offsets).toULineConfig()
+	return err != ErrConfigOverflow
+}
+
+// offsetsAt returns the offsets of the lines at the given positions.
+func (lro lineReqOptions) offsetsAt(positions []int) []int {
+	offsets := make([]int, len(positions))
+	for i, p := range positions {
+		offsets[i] = lro.offsets[p]
+	}
+	return offsets
+}
+
+// requestShards performs the request as a set of kernel requests, one for
+// each shard, populating the provided baseLine.
+func (c *Chip) requestShards(l *baseLine, lro lineReqOptions, shards [][]int) error {
+	l.offsets = lro.offsets
+	l.values = lro.values
+	l.chip = c.Name
+	l.abi = lro.abi
+	l.defCfg = lro.defCfg
+	var ec *eventChannel
+	if lro.ech != nil {
+		ec = newEventChannel(lro.ech.ctx, lro.ech.ch)
+		lro.eh = ec.handle
+	}
+	if lro.geh != nil {
+		lro.eh = lro.geh.forLines(c.Name, lro.offsets)
+	}
+	// dispatch to per-line handlers after the events from the shards are
+	// merged.
+	if lro.eh != nil || len(lro.handlers) != 0 {
+		l.dispatcher = newDispatcher(lro.eh, lro.handlers)
+		lro.eh = l.dispatcher.handle
+		lro.handlers = nil
+	}
+	if lro.eh != nil && (lro.queueSize > 0 || lro.workers > 0) {
+		l.queue = newEventQueue(lro.queueSize, lro.workers, lro.queuePolicy, lro.eh)
+		lro.eh = l.queue.handle
+		if l.dispatcher != nil {
+			// so waiters see any events dropped by the queue.
+			lro.eh = l.dispatcher.notifyBefore(lro.eh)
+		}
+	}
+	if lro.eh != nil && lro.initialState {
+		l.gate = newEventGate(lro.eh)
+		lro.eh = l.gate.handle
+	}
+	// merge the events from the shards into timestamp order, and renumber
+	// them so the seqno covers all the lines in the request.
+	var m *eventMerger
+	var eh EventHandler
+	mu := &sync.Mutex{}
+	if lro.eh != nil {
+		m = newEventMerger(shardMergeWindow, lro.eh)
+		eh = m.handle
+		mu = &m.mu
+	}
+	var oh OverflowHandler
+	if lro.oh != nil {
+		oh = func(ovf LineEventOverflow) {
+			mu.Lock()
+			defer mu.Unlock()
+			lro.oh(ovf)
+		}
+	}
+	var rs requestSet
+	for _, positions := range shards {
+		offsets := lro.offsetsAt(positions)
+		slro := lineReqOptions{
+			lineConfigOptions: lro.subset(offsets, offsets),
+			consumer:          lro.consumer,
+			abi:               lro.abi,
+			eh:                eh,
+			oh:                oh,
+			eventBufferSize:   lro.eventBufferSize,
+			pollPeriod:        lro.pollPeriod,
+			softDebounce:      lro.softDebounce,
+		}
+		ll := &Lines{}
+		if err := c.request(&ll.baseLine, slro); err != nil {
+			rs.close()
+			if m != nil {
+				m.Close()
+			}
+			if l.queue != nil {
+				l.queue.Close()
+			}
+			return err
+		}
+		rs = append(rs, setRequest{chip: c.Name, ll: ll, lines: positions})
+	}
+	l.isEvent = rs[0].ll.isEvent
+	l.shards = rs
+	// the shards own the kernel requests
+	l.watcher = shardCloser{rs: rs, m: m}
+	if ec != nil {
+		ec.start()
+		l.ech = ec
+	}
+	return l.openGate(lro.lineConfigOptions)
+}
+
+// shardCloser closes the shards of a sharded line request, and the merger of
+// their events.
+type shardCloser struct {
+	rs requestSet
+	m  *eventMerger
+}
+
+func (s shardCloser) Close() error {
+	err := firstError(s.rs.close())
+	if s.m != nil {
+		s.m.Close()
+	}
+	return err
+}
+
+// shardMergeWindow is the period the events from the shards of a request are
+// held, so that events from other shards with earlier timestamps can be
+// delivered before them.
+const shardMergeWindow = 10 * time.Millisecond
+
+// eventMerger merges the events read from the shards of a request into
+// timestamp order, and renumbers them so the seqno covers all the lines in the
+// request.
+//
+// The shards are read independently, so an event may be received after
+// events from other shards with later timestamps.  Each event is held for the
+// window after it is received, and is then passed on, along with any held
+// events with earlier timestamps, so events are delivered in timestamp order
+// unless delayed by more than the window.
+type eventMerger struct {
+	eh     EventHandler
+	window time.Duration
+
+	// mu serializes the events from the shards, and covers the fields below.
+	mu sync.Mutex
+
+	// the held events, in timestamp order.
+	pending []mergedEvent
+
+	timer  *time.Timer
+	seqno  uint32
+	closed bool
+}
+
+// mergedEvent is an event held by the eventMerger.
+type mergedEvent struct {
+	evt LineEvent
+
+	// when the event is to be passed on.
+	due time.Time
+}
+
+func newEventMerger(window time.Duration, eh EventHandler) *eventMerger {
+	return &eventMerger{eh: eh, window: window}
+}
+
+// handle is the EventHandler for the shards.
+func (m *eventMerger) handle(evt LineEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	now := time.Now()
+	// after any held events with the same timestamp, so those remain in the
+	// order received.
+	i := sort.Search(len(m.pending), func(i int) bool {
+		return m.pending[i].evt.Timestamp > evt.Timestamp
+	})
+	m.pending = append(m.pending, mergedEvent{})
+	copy(m.pending[i+1:], m.pending[i:])
+	m.pending[i] = mergedEvent{evt: evt, due: now.Add(m.window)}
+	m.flush(now)
+}
+
+// expire passes on the events that have been held for the window.
+func (m *eventMerger) expire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	m.flush(time.Now())
+}
+
+// flush passes on the held events up to and including the last that is due,
+// and sets the timer for the next event to become due.
+//
+// The caller must hold the lock.
+func (m *eventMerger) flush(now time.Time) {
+	last := -1
+	for i, me := range m.pending {
+		if !me.due.After(now) {
+			last = i
+		}
+	}
+	for _, me := range m.pending[:last+1] {
+		evt := me.evt
+		if evt.Seqno != 0 {
+			m.seqno++
+			evt.Seqno = m.seqno
+		}
+		m.eh(evt)
+	}
+	m.pending = append(m.pending[:0], m.pending[last+1:]...)
+	if len(m.pending) == 0 {
+		return
+	}
+	next := m.pending[0].due
+	for _, me := range m.pending[1:] {
+		if me.due.Before(next) {
+			next = me.due
+		}
+	}
+	if m.timer == nil {
+		m.timer = time.AfterFunc(next.Sub(now), m.expire)
+	} else {
+		m.timer.Reset(next.Sub(now))
+	}
+}
+
+// Close discards any held events and stops the merger.
+func (m *eventMerger) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.pending = nil
+}
+
+// firstError returns the first error from a set of errors, or nil if there
+// are none.
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachSubset calls fn for each request containing any of the lines at the
+// given positions in the set.
+//
+// The fn is passed the indices into positions of the lines in the request,
+// and the offsets of those lines.
+func (rs requestSet) eachSubset(positions []int, fn func(r setRequest, idx, offsets []int) error) []error {
+	return rs.each(func(r setRequest) error {
+		var idx, offsets []int
+		for i, p := range positions {
+			for j, rp := range r.lines {
+				if rp == p {
+					idx = append(idx, i)
+					offsets = append(offsets, r.ll.offsets[j])
+					break
+				}
+			}
+		}
+		if len(idx) == 0 {
+			return nil
+		}
+		return fn(r, idx, offsets)
+	})
+}
+
+// eventStats returns the sum of the event stats for all the shards.
+func (rs requestSet) eventStats() EventStats {
+	var es EventStats
+	for _, r := range rs {
+		ses := r.ll.EventStats()
+		es.Events += ses.Events
+		es.Overflowed += ses.Overflowed
+		es.Filtered += ses.Filtered
+		es.Dropped += ses.Dropped
+	}
+	return es
+}
@@ -0,0 +1,311 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"sync"
+)
+
+// SharedLine is a handle to a line request that is shared within the
+// process.
+//
+// The line is requested from the kernel by the first handle, and released
+// when the last handle is closed.
+type SharedLine struct {
+	sr *sharedRequest
+
+	// the handler for the edge events of this handle.
+	eh EventHandler
+
+	// the channel for the edge events of this handle, if any.
+	ech *eventChannel
+
+	// covered by the mutex of the shared request.
+	closed bool
+}
+
+// sharedKey identifies a line within the process.
+type sharedKey struct {
+	chip   string
+	offset int
+}
+
+// sharedRequest is the line request underlying a set of SharedLine handles.
+type sharedRequest struct {
+	key sharedKey
+
+	// the configuration all handles must request.
+	cfg LineConfig
+
+	// closed once the kernel request is complete, after which l is set if
+	// the request succeeded.
+	ready chan struct{}
+	l     *Line
+
+	// set, under the global lock, once the last handle is closed, after
+	// which the request is being released from the kernel.
+	releasing bool
+
+	// closed once the request has been released from the kernel.
+	released chan struct{}
+
+	// mu covers the handles and their closed flags.
+	mu      sync.Mutex
+	handles []*SharedLine
+
+	// dmu is held while events are passed to the handles, so Close can wait
+	// for any in-flight events.
+	dmu sync.Mutex
+}
+
+// sharedLines are the shared line requests of the process, including those
+// still being requested from, or released to, the kernel.
+var sharedLines = struct {
+	mu sync.Mutex
+	sr map[sharedKey]*sharedRequest
+}{sr: map[sharedKey]*sharedRequest{}}
+
+// RequestSharedLine requests a handle to a single line on a chip that may be
+// shared with other handles within the process.
+//
+// The chip and offset are as per RequestLine.
+func RequestSharedLine(chip string, offset int, options ...LineReqOption) (*SharedLine, error) {
+	c, err := NewChip(chip)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.RequestSharedLine(offset, options...)
+}
+
+// RequestSharedLine requests a handle to a single line on the chip that may be
+// shared with other handles within the process.
+//
+// The kernel only allows one request for a line, so the line is requested by
+// the first handle, and subsequent handles share that request.  The line is
+// released when all handles are closed.
+//
+// All handles must request the same line configuration, else the request
+// fails with an ErrSharedLineConflict.  The remaining options, such as the
+// consumer and the initial output value, are taken from the first request and
+// ignored for subsequent handles.
+//
+// The exception is the event handler or channel, provided by
+// WithEventHandler or WithEventChannel, which is specific to each handle.
+// Edge events on the line are passed to the handlers and channels of all open
+// handles.  Initial state events, if requested, are only passed to the first
+// handle.
+//
+// A shared request still conflicts with other requests for the line, so
+// requesting a line as both shared and unshared fails with ErrLineBusy.
+func (c *Chip) RequestSharedLine(offset int, options ...LineReqOption) (*SharedLine, error) {
+	if offset < 0 || offset >= c.lines {
+		return nil, ErrInvalidOffset
+	}
+	lro := c.newLineReqOptions([]int{offset}, options)
+	cfg := lro.config(offset)
+	h := &SharedLine{eh: lro.eh}
+	if lro.ech != nil {
+		h.ech = newEventChannel(lro.ech.ctx, lro.ech.ch)
+		h.eh = h.ech.handle
+	}
+	if lro.geh != nil {
+		h.eh = lro.geh.forLines(c.Name, []int{offset})
+	}
+	key := sharedKey{chip: c.Name, offset: offset}
+	for {
+		sharedLines.mu.Lock()
+		sr := sharedLines.sr[key]
+		if sr == nil {
+			// the handle is registered before the kernel request, so it
+			// receives any initial state events.
+			sr = &sharedRequest{
+				key:      key,
+				cfg:      cfg,
+				ready:    make(chan struct{}),
+				released: make(chan struct{}),
+				handles:  []*SharedLine{h},
+			}
+			h.sr = sr
+			sharedLines.sr[key] = sr
+			sharedLines.mu.Unlock()
+			if err := c.requestShared(sr, lro); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if sr.releasing {
+			// wait for the line to be released, then request it anew.
+			sharedLines.mu.Unlock()
+			<-sr.released
+			continue
+		}
+		if sr.cfg != cfg {
+			sharedLines.mu.Unlock()
+			return nil, ErrSharedLineConflict{
+				Chip:      c.Name,
+				Offset:    offset,
+				Config:    sr.cfg,
+				Requested: cfg,
+			}
+		}
+		sharedLines.mu.Unlock()
+		// wait for any pending request, which may fail or be released by
+		// its handles in the meantime.
+		<-sr.ready
+		sharedLines.mu.Lock()
+		if sharedLines.sr[key] == sr && !sr.releasing {
+			h.sr = sr
+			sr.mu.Lock()
+			sr.handles = append(sr.handles, h)
+			sr.mu.Unlock()
+			sharedLines.mu.Unlock()
+			break
+		}
+		sharedLines.mu.Unlock()
+	}
+	if h.ech != nil {
+		h.ech.start()
+	}
+	return h, nil
+}
+
+// requestShared requests the line from the kernel for the shared request,
+// which is pending in sharedLines.
+//
+// The global lock is not held while the kernel request is made, so other
+// handles for the line wait on ready, without blocking requests for other
+// lines.
+func (c *Chip) requestShared(sr *sharedRequest, lro lineReqOptions) error {
+	// events are fanned out to the handles.
+	lro.eh = nil
+	lro.ech = nil
+	lro.geh = nil
+	if sr.cfg.EdgeDetection != LineEdgeNone {
+		lro.eh = sr.handle
+	}
+	l := &Line{}
+	err := c.requestWithOptions(&l.baseLine, lro)
+	sharedLines.mu.Lock()
+	if err == nil {
+		sr.l = l
+	} else {
+		delete(sharedLines.sr, sr.key)
+	}
+	close(sr.ready)
+	sharedLines.mu.Unlock()
+	return err
+}
+
+// handle passes the event to the event handlers of the open handles.
+func (sr *sharedRequest) handle(evt LineEvent) {
+	sr.dmu.Lock()
+	defer sr.dmu.Unlock()
+	sr.mu.Lock()
+	hh := make([]EventHandler, 0, len(sr.handles))
+	for _, h := range sr.handles {
+		if h.eh != nil {
+			hh = append(hh, h.eh)
+		}
+	}
+	sr.mu.Unlock()
+	for _, eh := range hh {
+		eh(evt)
+	}
+}
+
+// Chip returns the name of the chip from which the line was requested.
+func (h *SharedLine) Chip() string {
+	return h.sr.key.chip
+}
+
+// Offset returns the offset of the line within the chip.
+func (h *SharedLine) Offset() int {
+	return h.sr.key.offset
+}
+
+// Close closes the handle, and releases the line if this is the last open
+// handle to the line.
+//
+// Close waits for any events being passed to the handle to be delivered, so
+// no events are passed to the handle once Close returns.  As a consequence
+// Close must not be called from the context of the event handler of a handle
+// to the same line.
+func (h *SharedLine) Close() error {
+	sharedLines.mu.Lock()
+	sr := h.sr
+	sr.mu.Lock()
+	if h.closed {
+		sr.mu.Unlock()
+		sharedLines.mu.Unlock()
+		return ErrClosed
+	}
+	h.closed = true
+	for i, hh := range sr.handles {
+		if hh == h {
+			sr.handles = append(sr.handles[:i], sr.handles[i+1:]...)
+			break
+		}
+	}
+	last := len(sr.handles) == 0
+	sr.mu.Unlock()
+	if last {
+		// subsequent requests for the line wait for it to be released.
+		sr.releasing = true
+	}
+	sharedLines.mu.Unlock()
+	if h.ech != nil {
+		// unblock any pending send before waiting on the dispatch.
+		h.ech.stop()
+	}
+	// wait for any events in flight to the handle.
+	sr.dmu.Lock()
+	sr.dmu.Unlock()
+	if h.ech != nil {
+		h.ech.close()
+	}
+	if !last {
+		return nil
+	}
+	err := sr.l.Close()
+	sharedLines.mu.Lock()
+	delete(sharedLines.sr, sr.key)
+	close(sr.released)
+	sharedLines.mu.Unlock()
+	return err
+}
+
+// Info returns the information about the line.
+func (h *SharedLine) Info() (LineInfo, error) {
+	if h.isClosed() {
+		return LineInfo{}, ErrClosed
+	}
+	return h.sr.l.Info()
+}
+
+// Value returns the current value (active state) of the line.
+func (h *SharedLine) Value() (int, error) {
+	if h.isClosed() {
+		return 0, ErrClosed
+	}
+	return h.sr.l.Value()
+}
+
+// SetValue sets the current active state of the line.
+//
+// Only valid for output lines.  The line is shared, so the value may be
+// subsequently altered by other handles.
+func (h *SharedLine) SetValue(value int) error {
+	if h.isClosed() {
+		return ErrClosed
+	}
+	return h.sr.l.SetValue(value)
+}
+
+func (h *SharedLine) isClosed() bool {
+	h.sr.mu.Lock()
+	defer h.sr.mu.Unlock()
+	return h.closed
+}
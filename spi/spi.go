@@ -67,13 +67,15 @@ func New(c *gpiod.Chip, sclk, ssz, mosi, miso int, options ...Option) (*SPI, err
 			s.Close()
 		}
 	}()
+	// revert all lines to inputs when released
+	release := gpiod.WithReleaseState(gpiod.AsInput)
 	// hold SPI reset until needed...
-	l, err = c.RequestLine(ssz, gpiod.AsOutput(1))
+	l, err = c.RequestLine(ssz, gpiod.AsOutput(1), release)
 	if err != nil {
 		return nil, err
 	}
 	s.Ssz = l
-	clkOpts := []gpiod.LineReqOption{gpiod.AsOutput(0)}
+	clkOpts := []gpiod.LineReqOption{gpiod.AsOutput(0), release}
 	if s.cpol != 0 {
 		clkOpts = append(clkOpts, gpiod.AsActiveLow)
 	}
@@ -82,7 +84,7 @@ func New(c *gpiod.Chip, sclk, ssz, mosi, miso int, options ...Option) (*SPI, err
 		return nil, err
 	}
 	s.Sclk = l
-	l, err = c.RequestLine(miso, gpiod.AsInput)
+	l, err = c.RequestLine(miso, gpiod.AsInput, release)
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +92,7 @@ func New(c *gpiod.Chip, sclk, ssz, mosi, miso int, options ...Option) (*SPI, err
 	if miso == mosi {
 		s.Mosi = s.Miso
 	} else {
-		l, err := c.RequestLine(mosi, gpiod.AsOutput(0))
+		l, err := c.RequestLine(mosi, gpiod.AsOutput(0), release)
 		if err != nil {
 			return nil, err
 		}
@@ -102,18 +104,15 @@ func New(c *gpiod.Chip, sclk, ssz, mosi, miso int, options ...Option) (*SPI, err
 // Close releases allocated resources and reverts all output lines to inputs.
 func (s *SPI) Close() {
 	if s.Sclk != nil {
-		s.Sclk.Reconfigure(gpiod.AsInput)
 		s.Sclk.Close()
 	}
 	if s.Mosi != nil {
-		s.Mosi.Reconfigure(gpiod.AsInput)
 		s.Mosi.Close()
 	}
 	if s.Miso != nil && s.Mosi != s.Miso {
 		s.Miso.Close()
 	}
 	if s.Ssz != nil {
-		s.Ssz.Reconfigure(gpiod.AsInput)
 		s.Ssz.Close()
 	}
 }
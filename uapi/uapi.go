@@ -185,6 +185,32 @@ func ReadEvent(fd uintptr) (EventData, error) {
 	return ed, err
 }
 
+// ReadEvents reads as many events as are available from a requested line,
+// up to the size of the events buffer, in a single read.
+//
+// The fd is a requested line, as returned by GetLineEvent.
+//
+// Returns the number of events read.
+//
+// If the fd is blocking then this function blocks until at least one event is
+// available.
+//
+// Reading multiple events in a single read requires Linux v5.7 or later.
+// Earlier kernels return at most one event per read.
+func ReadEvents(fd uintptr, events []EventData) (int, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+	size := int(unsafe.Sizeof(events[0]))
+	// the kernel writes whole events in native layout, so read them in place.
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&events[0])), size*len(events))
+	n, err := unix.Read(int(fd), buf)
+	if err != nil {
+		return 0, err
+	}
+	return n / size, nil
+}
+
 // ReadLineInfoChanged reads a line info changed event from a chip.
 //
 // The fd is an open GPIO character device.
@@ -1566,6 +1566,52 @@ func TestReadEvent(t *testing.T) {
 	assert.Equal(t, uapi.EventRequestRisingEdge, evt.ID)
 }
 
+func TestReadEvents(t *testing.T) {
+	s, err := gpiosim.NewSimpleton(4)
+	require.Nil(t, err)
+	defer s.Close()
+	f, err := os.Open(s.DevPath())
+	require.Nil(t, err)
+	defer f.Close()
+	offset := 1
+	err = s.SetPull(offset, 0)
+	require.Nil(t, err)
+	er := uapi.EventRequest{
+		Offset:      uint32(offset),
+		HandleFlags: uapi.HandleRequestInput,
+		EventFlags:  uapi.EventRequestBothEdges,
+	}
+	err = uapi.GetLineEvent(f.Fd(), &er)
+	require.Nil(t, err)
+	defer unix.Close(int(er.Fd))
+
+	// empty buffer
+	n, err := uapi.ReadEvents(uintptr(er.Fd), nil)
+	assert.Nil(t, err)
+	assert.Zero(t, n)
+
+	s.SetPull(offset, 1)
+	s.SetPull(offset, 0)
+	xids := []uapi.EventFlag{uapi.EventRequestRisingEdge, uapi.EventRequestFallingEdge}
+	var ids []uapi.EventFlag
+	buf := make([]uapi.EventData, 4)
+	pollfd := unix.PollFd{Fd: er.Fd, Events: unix.POLLIN}
+	for len(ids) < len(xids) {
+		n, err := unix.Poll([]unix.PollFd{pollfd}, int(eventWaitTimeout.Milliseconds()))
+		if err == unix.EINTR {
+			continue
+		}
+		require.Nil(t, err)
+		require.Equal(t, 1, n, "missing event")
+		n, err = uapi.ReadEvents(uintptr(er.Fd), buf)
+		require.Nil(t, err)
+		for _, evt := range buf[:n] {
+			ids = append(ids, evt.ID)
+		}
+	}
+	assert.Equal(t, xids, ids)
+}
+
 func readEventTimeout(fd int32, t time.Duration) (*uapi.EventData, error) {
 	pollfd := unix.PollFd{Fd: int32(fd), Events: unix.POLLIN}
 	for {
@@ -119,6 +119,29 @@ func ReadLineEvent(fd uintptr) (LineEvent, error) {
 	return le, err
 }
 
+// ReadLineEvents reads as many events as are available from a requested line,
+// up to the size of the events buffer, in a single read.
+//
+// The fd is a requested line, as returned by GetLine.
+//
+// Returns the number of events read.
+//
+// If the fd is blocking then this function blocks until at least one event is
+// available.
+func ReadLineEvents(fd uintptr, events []LineEvent) (int, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+	size := int(unsafe.Sizeof(events[0]))
+	// the kernel writes whole events in native layout, so read them in place.
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&events[0])), size*len(events))
+	n, err := unix.Read(int(fd), buf)
+	if err != nil {
+		return 0, err
+	}
+	return n / size, nil
+}
+
 // ReadLineInfoChangedV2 reads a line info changed event from a chip.
 //
 // The fd is an open GPIO character device.
@@ -240,6 +263,10 @@ const (
 	// the source for event timestamps.
 	LineFlagV2EventClockRealtime
 
+	// LineFlagV2EventClockHTE indicates that the hardware timestamp engine
+	// will be the source for event timestamps.
+	LineFlagV2EventClockHTE
+
 	// LineFlagV2DirectionMask is a mask for all direction flags.
 	LineFlagV2DirectionMask = LineFlagV2Input | LineFlagV2Output
 
@@ -328,6 +355,12 @@ func (f LineFlagV2) HasRealtimeEventClock() bool {
 	return f&LineFlagV2EventClockRealtime != 0
 }
 
+// HasHTEEventClock returns true if the line events will contain timestamps
+// from the hardware timestamp engine.
+func (f LineFlagV2) HasHTEEventClock() bool {
+	return f&LineFlagV2EventClockHTE != 0
+}
+
 // Encode creates a LineAttribute with the value from the LineFlagV2.
 func (f LineFlagV2) Encode() (la LineAttribute) {
 	la.Encode64(LineAttributeIDFlags, uint64(f))
@@ -2296,6 +2296,86 @@ func TestReadLineEvent(t *testing.T) {
 	unix.Close(int(lr.Fd))
 }
 
+func TestReadLineEvents(t *testing.T) {
+	requireKernel(t, uapiV2Kernel)
+	s, err := gpiosim.NewSimpleton(4)
+	require.Nil(t, err)
+	defer s.Close()
+	f, err := os.Open(s.DevPath())
+	require.Nil(t, err)
+	defer f.Close()
+	err = s.SetPull(1, 0)
+	require.Nil(t, err)
+	err = s.SetPull(2, 0)
+	require.Nil(t, err)
+
+	lr := uapi.LineRequest{
+		Lines:   2,
+		Offsets: [uapi.LinesMax]uint32{1, 2},
+		Config: uapi.LineConfig{
+			Flags: uapi.LineFlagV2Input | uapi.LineFlagV2EdgeBoth,
+		},
+	}
+	err = uapi.GetLine(f.Fd(), &lr)
+	require.Nil(t, err)
+	defer unix.Close(int(lr.Fd))
+
+	// empty buffer
+	n, err := uapi.ReadLineEvents(uintptr(lr.Fd), nil)
+	assert.Nil(t, err)
+	assert.Zero(t, n)
+
+	s.SetPull(1, 1)
+	s.SetPull(2, 1)
+	s.SetPull(1, 0)
+	xevts := []uapi.LineEvent{
+		{Offset: 1, ID: uapi.LineEventRisingEdge, Seqno: 1, LineSeqno: 1},
+		{Offset: 2, ID: uapi.LineEventRisingEdge, Seqno: 2, LineSeqno: 1},
+		{Offset: 1, ID: uapi.LineEventFallingEdge, Seqno: 3, LineSeqno: 2},
+	}
+	evts := readLineEventsTimeout(t, lr.Fd, 5, len(xevts), eventWaitTimeout)
+	for i := range evts {
+		evts[i].Timestamp = 0
+	}
+	assert.Equal(t, xevts, evts)
+
+	// buffer smaller than available
+	s.SetPull(2, 0)
+	s.SetPull(1, 1)
+	time.Sleep(eventWaitTimeout)
+	buf := make([]uapi.LineEvent, 1)
+	n, err = uapi.ReadLineEvents(uintptr(lr.Fd), buf)
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+	assert.Equal(t, uint32(2), buf[0].Offset)
+	n, err = uapi.ReadLineEvents(uintptr(lr.Fd), buf)
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+	assert.Equal(t, uint32(1), buf[0].Offset)
+}
+
+// readLineEventsTimeout reads events, in batches of up to size, until count
+// events have been read or the timeout expires.
+func readLineEventsTimeout(t *testing.T, fd int32, size, count int, timeout time.Duration) []uapi.LineEvent {
+	var evts []uapi.LineEvent
+	buf := make([]uapi.LineEvent, size)
+	pollfd := unix.PollFd{Fd: int32(fd), Events: unix.POLLIN}
+	for len(evts) < count {
+		n, err := unix.Poll([]unix.PollFd{pollfd}, int(timeout.Milliseconds()))
+		if err == unix.EINTR {
+			continue
+		}
+		require.Nil(t, err)
+		if n != 1 {
+			break
+		}
+		n, err = uapi.ReadLineEvents(uintptr(fd), buf)
+		require.Nil(t, err)
+		evts = append(evts, buf[:n]...)
+	}
+	return evts
+}
+
 func readLineEventTimeout(fd int32, t time.Duration) (*uapi.LineEvent, error) {
 	pollfd := unix.PollFd{Fd: int32(fd), Events: unix.POLLIN}
 	for {
@@ -2762,6 +2842,7 @@ func TestLineFlagsV2(t *testing.T) {
 	assert.False(t, uapi.LineFlagV2(0).IsBiasPullUp())
 	assert.False(t, uapi.LineFlagV2(0).IsBiasPullDown())
 	assert.False(t, uapi.LineFlagV2(0).HasRealtimeEventClock())
+	assert.False(t, uapi.LineFlagV2(0).HasHTEEventClock())
 	assert.False(t, uapi.LineFlagV2Used.IsAvailable())
 	assert.True(t, uapi.LineFlagV2Used.IsUsed())
 	assert.True(t, uapi.LineFlagV2ActiveLow.IsActiveLow())
@@ -2776,4 +2857,9 @@ func TestLineFlagsV2(t *testing.T) {
 	assert.True(t, uapi.LineFlagV2BiasPullUp.IsBiasPullUp())
 	assert.True(t, uapi.LineFlagV2BiasPullDown.IsBiasPullDown())
 	assert.True(t, uapi.LineFlagV2EventClockRealtime.HasRealtimeEventClock())
+	assert.False(t, uapi.LineFlagV2EventClockRealtime.HasHTEEventClock())
+	assert.True(t, uapi.LineFlagV2EventClockHTE.HasHTEEventClock())
+	assert.False(t, uapi.LineFlagV2EventClockHTE.HasRealtimeEventClock())
+	// matches GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE
+	assert.Equal(t, uapi.LineFlagV2(1<<12), uapi.LineFlagV2EventClockHTE)
 }
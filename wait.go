@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: 2026 Kent Gibson <taemon1337@gmail.com>
+//
+// SPDX-License-Identifier: MIT
+
+package gpiod
+
+import (
+	"context"
+	"time"
+)
+
+// waitPollPeriod is the maximum time a wait reading events directly from the
+// line request blocks before checking if the context is done.
+const waitPollPeriod = 100 * time.Millisecond
+
+// WaitFor waits until the active state of the line is the given value, or the
+// context is done.
+//
+// Returns immediately if the line is already at the value, else waits for an
+// edge event that leaves the line at the value.  The line must be requested
+// with edge detection for that edge, else ErrNoEdgeDetection is returned.
+//
+// If the line is requested with an event handler or channel then the events
+// are also passed to the handler or channel.  Otherwise the events are read
+// directly from the line request and are discarded, and so require uAPI v2.
+// The wait is not affected by events dropped by an event queue.
+//
+// Returns the context error if the context is done before the line reaches
+// the value, and ErrClosed if the line is closed.
+func (l *Line) WaitFor(ctx context.Context, value int) error {
+	_, err := l.waitFor(ctx, map[int]int{l.offsets[0]: value})
+	return err
+}
+
+// WaitForAny waits until the active state of any of a subset of the lines
+// is the value for that line, or the context is done.
+//
+// The subset is identified by the offsets keying the values map, which must
+// all be in the request.
+//
+// Returns the offset of a line that has reached its value, else waits as per
+// Line.WaitFor.
+func (l *Lines) WaitForAny(ctx context.Context, values map[int]int) (int, error) {
+	return l.waitFor(ctx, values)
+}
+
+func (l *baseLine) waitFor(ctx context.Context, values map[int]int) (int, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return 0, ErrClosed
+	}
+	// check the lines in the order they were requested
+	var offsets []int
+	for _, offset := range l.offsets {
+		if _, ok := values[offset]; ok {
+			offsets = append(offsets, offset)
+		}
+	}
+	if len(offsets) != len(values) {
+		l.mu.Unlock()
+		return 0, ErrInvalidOffset
+	}
+	lco := lineConfigOptions{
+		offsets: l.offsets,
+		defCfg:  l.defCfg,
+		lineCfg: l.lineCfg,
+	}
+	for _, offset := range offsets {
+		edge := LineEdgeFalling
+		if values[offset] != 0 {
+			edge = LineEdgeRising
+		}
+		if lco.config(offset).EdgeDetection&edge == 0 {
+			l.mu.Unlock()
+			return 0, ErrNoEdgeDetection
+		}
+	}
+	d := l.dispatcher
+	l.mu.Unlock()
+	if d == nil {
+		return l.waitEvents(ctx, offsets, values)
+	}
+	// watch for edges before reading the values, so no edge is missed.
+	w := d.wait(values)
+	defer d.unwait(w)
+	if offset, ok, err := l.reached(offsets, values); ok || err != nil {
+		return offset, err
+	}
+	select {
+	case offset := <-w.ch:
+		return offset, nil
+	case <-d.done:
+		return 0, ErrClosed
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// waitEvents waits for the lines to reach their values by reading edge events
+// directly from the line request.
+func (l *baseLine) waitEvents(ctx context.Context, offsets []int, values map[int]int) (int, error) {
+	if err := l.checkEventReader(); err != nil {
+		return 0, err
+	}
+	// discard the events that preceded the wait, and then read the values,
+	// so any subsequent edge is in the event buffer.
+	evts := make([]LineEvent, 16)
+	for {
+		ok, err := l.WaitEvent(0)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		if _, err := l.ReadEvents(evts); err != nil {
+			return 0, err
+		}
+	}
+	if offset, ok, err := l.reached(offsets, values); ok || err != nil {
+		return offset, err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		timeout := waitPollPeriod
+		if deadline, ok := ctx.Deadline(); ok {
+			// a negative timeout would wait indefinitely.
+			if remaining := time.Until(deadline); remaining < 0 {
+				timeout = 0
+			} else if remaining < timeout {
+				timeout = remaining
+			}
+		}
+		ok, err := l.WaitEvent(timeout)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		n, err := l.ReadEvents(evts)
+		if err != nil {
+			return 0, err
+		}
+		for _, evt := range evts[:n] {
+			if v, ok := values[evt.Offset]; ok && v == evt.Value {
+				return evt.Offset, nil
+			}
+		}
+	}
+}
+
+// reached returns the offset of the first line that is currently at its
+// value, and true, if any.
+func (l *baseLine) reached(offsets []int, values map[int]int) (int, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return 0, false, ErrClosed
+	}
+	positions, err := l.positions(offsets)
+	if err != nil {
+		return 0, false, err
+	}
+	vv, err := l.valuesAt(positions)
+	if err != nil {
+		return 0, false, err
+	}
+	for i, offset := range offsets {
+		if vv[i] == values[offset] {
+			return offset, true, nil
+		}
+	}
+	return 0, false, nil
+}
@@ -5,205 +5,709 @@
 package gpiod
 
 import (
-	"fmt"
+	"context"
+	"os"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/taemon1337/gpiod/uapi"
 	"golang.org/x/sys/unix"
 )
 
+// eventReader reads the available events from a line request fd.
+//
+// The returned slice is only valid until the next call to the reader.
+type eventReader func(fd uintptr) ([]LineEvent, error)
+
+// maxEventBatchSize limits the number of events read from a line request fd
+// in a single read.
+const maxEventBatchSize = 1024
+
+// watcher reads edge events from line requests and forwards them to the
+// event handler.
+//
+// The line request fds are set non-blocking and are waited on using the Go
+// runtime poller, so the goroutines reading the events are parked while
+// waiting.  The fds of a uAPI v1 request, one per line, are multiplexed by an
+// epoll fd so the request is read by a single goroutine.
+//
+// The watcher takes ownership of the line request fds, and closes them when
+// it is closed.
 type watcher struct {
-	epfd int
+	// the fds being waited on, wrapped for the runtime poller.
+	files []*os.File
+
+	// the readers of the files.
+	readers []eventReader
 
-	// eventfd to signal watcher to shutdown
-	donefd int
+	// the line request fds multiplexed by the files, if not the files
+	// themselves.
+	fds []int
 
 	// the handler for detected events
 	eh EventHandler
 
-	// closed once watcher exits
-	doneCh chan struct{}
+	// mu serializes calls to eh from multiple files.
+	mu sync.Mutex
+
+	// the goroutines reading the files.
+	wg sync.WaitGroup
 }
 
-func newWatcher(fd int32, eh EventHandler) (w *watcher, err error) {
-	var epfd, donefd int
-	epfd, err = unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+// newWatcher creates a watcher for a uAPI v2 line request fd, reading up to
+// batchSize events at a time.
+func newWatcher(fd int32, batchSize int, eh EventHandler) (*watcher, error) {
+	f, err := newPollFile(int(fd))
 	if err != nil {
-		return
+		return nil, err
 	}
-	defer func() {
-		if err != nil {
-			unix.Close(epfd)
-		}
-	}()
-	donefd, err = unix.Eventfd(0, unix.EFD_CLOEXEC)
+	w := &watcher{eh: eh}
+	w.watch(f, newLineEventReader(batchSize))
+	return w, nil
+}
+
+// newWatcherV1 creates a watcher for uAPI v1 event request fds, which are
+// mapped to the offset of their line.
+//
+// The fds are multiplexed by an epoll instance, so the events for all the
+// lines of the request are read by a single goroutine.
+func newWatcherV1(fds map[int]int, eh EventHandler) (*watcher, error) {
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
 	if err != nil {
-		return
+		return nil, err
 	}
-	defer func() {
-		if err != nil {
-			unix.Close(donefd)
+	for fd := range fds {
+		if err = unix.SetNonblock(fd, true); err != nil {
+			unix.Close(epfd)
+			return nil, err
+		}
+		ev := unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(fd)}
+		if err = unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, fd, &ev); err != nil {
+			unix.Close(epfd)
+			return nil, err
 		}
-	}()
-	epv := unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(donefd)}
-	err = unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, int(donefd), &epv)
-	if err != nil {
-		return
 	}
-	epv.Fd = int32(fd)
-	err = unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, int(fd), &epv)
+	f, err := newPollFile(epfd)
 	if err != nil {
-		return
+		unix.Close(epfd)
+		return nil, err
+	}
+	w := &watcher{eh: eh}
+	for fd := range fds {
+		w.fds = append(w.fds, fd)
 	}
-	w = &watcher{
-		epfd:   epfd,
-		donefd: donefd,
-		eh:     eh,
-		doneCh: make(chan struct{}),
+	w.watch(f, newEpollEventReaderV1(fds))
+	return w, nil
+}
+
+// newPollFile wraps the fd in an os.File that is registered with the runtime
+// poller.
+func newPollFile(fd int) (*os.File, error) {
+	if err := unix.SetNonblock(fd, true); err != nil {
+		return nil, err
 	}
-	go w.watch()
-	return
+	return os.NewFile(uintptr(fd), "gpio-line-request"), nil
 }
 
+// Close stops the watcher and closes the line request fds.
+//
+// Waits for any running event handler to return.
 func (w *watcher) Close() error {
-	unix.Write(w.donefd, []byte{1, 0, 0, 0, 0, 0, 0, 0})
-	<-w.doneCh
-	unix.Close(w.donefd)
+	for _, f := range w.files {
+		f.Close()
+	}
+	w.wg.Wait()
+	for _, fd := range w.fds {
+		unix.Close(fd)
+	}
 	return nil
 }
 
-func (w *watcher) watch() {
-	epollEvents := make([]unix.EpollEvent, 2)
-	defer close(w.doneCh)
-	for {
-		n, err := unix.EpollWait(w.epfd, epollEvents[:], -1)
+// pause stops the reading of events, without closing the line request fds,
+// and waits for any running event handler to return.
+func (w *watcher) pause() {
+	// an expired deadline unblocks, and ends, the reading goroutines.
+	for _, f := range w.files {
+		f.SetReadDeadline(time.Unix(1, 0))
+	}
+	w.wg.Wait()
+}
+
+// resume restarts the reading of events after a pause.
+func (w *watcher) resume() {
+	for i, f := range w.files {
+		f.SetReadDeadline(time.Time{})
+		w.run(f, w.readers[i])
+	}
+}
+
+func (w *watcher) watch(f *os.File, read eventReader) {
+	w.files = append(w.files, f)
+	w.readers = append(w.readers, read)
+	w.run(f, read)
+}
+
+// run starts a goroutine reading events from the file.
+func (w *watcher) run(f *os.File, read eventReader) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		rc, err := f.SyscallConn()
 		if err != nil {
-			if err == unix.EBADF || err == unix.EINVAL {
-				// fd closed so exit
+			return
+		}
+		for {
+			var evts []LineEvent
+			var rerr error
+			err = rc.Read(func(fd uintptr) bool {
+				evts, rerr = read(fd)
+				return rerr != unix.EAGAIN
+			})
+			if err != nil {
+				// file closed, or reading paused
 				return
 			}
-			if err == unix.EINTR {
+			if rerr == unix.EINTR {
 				continue
 			}
-			panic(fmt.Sprintf("EpollWait unexpected error: %v", err))
-		}
-		for i := 0; i < n; i++ {
-			ev := epollEvents[i]
-			fd := ev.Fd
-			if fd == int32(w.donefd) {
-				unix.Close(w.epfd)
+			if rerr != nil {
+				// line request is no longer readable, e.g. the chip has
+				// been removed.
 				return
 			}
-			evt, err := uapi.ReadLineEvent(uintptr(fd))
-			if err != nil {
-				continue
+			w.mu.Lock()
+			for _, evt := range evts {
+				w.eh(evt)
 			}
-			le := LineEvent{
-				Offset:    int(evt.Offset),
-				Timestamp: time.Duration(evt.Timestamp),
-				Type:      LineEventType(evt.ID),
-				Seqno:     evt.Seqno,
-				LineSeqno: evt.LineSeqno,
+			w.mu.Unlock()
+		}
+	}()
+}
+
+// newLineEventReader returns an eventReader for a uAPI v2 line request fd
+// that reads up to size events at a time.
+func newLineEventReader(size int) eventReader {
+	if size > maxEventBatchSize {
+		size = maxEventBatchSize
+	}
+	if size < 1 {
+		size = 1
+	}
+	buf := make([]uapi.LineEvent, size)
+	evts := make([]LineEvent, size)
+	return func(fd uintptr) ([]LineEvent, error) {
+		n, err := uapi.ReadLineEvents(fd, buf)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			evts[i] = newLineEvent(buf[i])
+		}
+		return evts[:n], nil
+	}
+}
+
+// newLineEvent converts a uAPI v2 event to a LineEvent.
+func newLineEvent(evt uapi.LineEvent) LineEvent {
+	return LineEvent{
+		Offset:    int(evt.Offset),
+		Timestamp: time.Duration(evt.Timestamp),
+		Type:      LineEventType(evt.ID),
+		Value:     LineEventType(evt.ID).value(),
+		Seqno:     evt.Seqno,
+		LineSeqno: evt.LineSeqno,
+	}
+}
+
+// eventClocks records the event clock of each line in a request, so the
+// events read from the request can identify their clock.
+type eventClocks struct {
+	// mu covers clocks, which may be altered by Reconfigure.
+	mu sync.Mutex
+
+	// the clocks of the lines not using the monotonic clock, by offset.
+	clocks map[int]LineEventClock
+}
+
+func newEventClocks(lco lineConfigOptions) *eventClocks {
+	ec := &eventClocks{}
+	ec.configure(lco)
+	return ec
+}
+
+// configure updates the clocks of the lines.
+func (ec *eventClocks) configure(lco lineConfigOptions) {
+	clocks := map[int]LineEventClock{}
+	for _, offset := range lco.offsets {
+		if clock := lco.config(offset).EventClock; clock != LineEventClockMonotonic {
+			clocks[offset] = clock
+		}
+	}
+	ec.mu.Lock()
+	ec.clocks = clocks
+	ec.mu.Unlock()
+}
+
+// stamp sets the clock of the event.
+func (ec *eventClocks) stamp(evt *LineEvent) {
+	ec.mu.Lock()
+	evt.Clock = ec.clocks[evt.Offset]
+	ec.mu.Unlock()
+}
+
+// wrap returns an EventHandler that sets the clock of events before passing
+// them to eh.
+func (ec *eventClocks) wrap(eh EventHandler) EventHandler {
+	return func(evt LineEvent) {
+		ec.stamp(&evt)
+		eh(evt)
+	}
+}
+
+// dispatcher passes edge events to the handler for the line, if any, else to
+// the handler for the request, if any.
+//
+// The events are also passed to any waiters.
+type dispatcher struct {
+	eh EventHandler
+
+	// set if the waiters are notified by notifyBefore rather than handle.
+	// Set before any events are dispatched.
+	early bool
+
+	// closed when the requested lines are closed.
+	done chan struct{}
+
+	// mu covers all that follow, which may be altered while events are
+	// dispatched.
+	mu       sync.Mutex
+	handlers map[int]EventHandler
+	waiters  map[*waiter]struct{}
+}
+
+// waiter is waiting for one of a set of lines to reach a value.
+type waiter struct {
+	// the values being waited for, keyed by offset.
+	values map[int]int
+
+	// receives the offset of the first line to reach its value.
+	ch chan int
+}
+
+func newDispatcher(eh EventHandler, handlers map[int]EventHandler) *dispatcher {
+	d := &dispatcher{
+		eh:       eh,
+		done:     make(chan struct{}),
+		handlers: map[int]EventHandler{},
+		waiters:  map[*waiter]struct{}{},
+	}
+	d.update(handlers)
+	return d
+}
+
+// wait registers a waiter for the lines to reach the values, keyed by offset.
+//
+// The waiter must be removed with unwait once the wait is complete.
+func (d *dispatcher) wait(values map[int]int) *waiter {
+	w := &waiter{values: values, ch: make(chan int, 1)}
+	d.mu.Lock()
+	d.waiters[w] = struct{}{}
+	d.mu.Unlock()
+	return w
+}
+
+// unwait removes the waiter.
+func (d *dispatcher) unwait(w *waiter) {
+	d.mu.Lock()
+	delete(d.waiters, w)
+	d.mu.Unlock()
+}
+
+// close unblocks any waiters.
+func (d *dispatcher) close() {
+	close(d.done)
+}
+
+// update applies changes to the per-line handlers, with nil handlers removing
+// the handler for the line.
+func (d *dispatcher) update(handlers map[int]EventHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for offset, eh := range handlers {
+		if eh == nil {
+			delete(d.handlers, offset)
+		} else {
+			d.handlers[offset] = eh
+		}
+	}
+}
+
+// notifyBefore returns an EventHandler that notifies the waiters of events
+// before passing them to eh, rather than when the events are dispatched.
+//
+// This allows the waiters to see events that eh may discard, such as when eh
+// is an event queue.
+func (d *dispatcher) notifyBefore(eh EventHandler) EventHandler {
+	d.early = true
+	return func(evt LineEvent) {
+		d.mu.Lock()
+		d.notify(evt)
+		d.mu.Unlock()
+		eh(evt)
+	}
+}
+
+// notify passes the event to any waiters for the value.
+//
+// The caller must hold the lock.
+func (d *dispatcher) notify(evt LineEvent) {
+	for w := range d.waiters {
+		if v, ok := w.values[evt.Offset]; ok && v == evt.Value {
+			select {
+			case w.ch <- evt.Offset:
+			default:
 			}
-			w.eh(le)
 		}
 	}
 }
 
-type watcherV1 struct {
-	watcher
+// handle is the EventHandler for the events from the request.
+func (d *dispatcher) handle(evt LineEvent) {
+	d.mu.Lock()
+	if !d.early {
+		d.notify(evt)
+	}
+	eh := d.handlers[evt.Offset]
+	d.mu.Unlock()
+	if eh == nil {
+		eh = d.eh
+	}
+	if eh != nil {
+		eh(evt)
+	}
+}
+
+// eventGate holds back the events from a request while the initial state
+// events for lines are generated, so the initial state of a line is reported
+// before any of its edges.
+//
+// The initial state events, and any events held back, are passed to the event
+// handler from a goroutine started by open, so the handler is never called by
+// the caller of open, and so may call methods on the request.
+type eventGate struct {
+	eh EventHandler
+
+	// the lines with edge detection, covered by the lock of the request.
+	started map[int]bool
+
+	// emu serializes calls to eh, and is held while eh is called.
+	emu sync.Mutex
 
-	// fd to offset mapping
-	evtfds map[int]int
+	// mu covers all that follow.
+	mu sync.Mutex
+	// the events yet to be passed to eh.
+	pending []LineEvent
+	// the number of pending events that preceded the shutting of the gate,
+	// and so may be passed on while it is shut.
+	hold     int
+	shut     bool
+	flushing bool
+	closed   bool
+
+	// the goroutine passing the pending events.
+	wg sync.WaitGroup
 }
 
-func newWatcherV1(fds map[int]int, eh EventHandler) (w *watcherV1, err error) {
-	var epfd, donefd int
-	epfd, err = unix.EpollCreate1(unix.EPOLL_CLOEXEC)
-	if err != nil {
+// newEventGate creates an eventGate that is initially shut.
+func newEventGate(eh EventHandler) *eventGate {
+	return &eventGate{eh: eh, shut: true}
+}
+
+// handle is the EventHandler for the events from the request.
+func (g *eventGate) handle(evt LineEvent) {
+	g.emu.Lock()
+	defer g.emu.Unlock()
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
 		return
 	}
-	defer func() {
-		if err != nil {
-			unix.Close(epfd)
-		}
-	}()
-	donefd, err = unix.Eventfd(0, unix.EFD_CLOEXEC)
-	if err != nil {
+	if g.shut || g.flushing {
+		g.pending = append(g.pending, evt)
+		g.mu.Unlock()
 		return
 	}
-	defer func() {
-		if err != nil {
-			unix.Close(donefd)
-		}
-	}()
-	epv := unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(donefd)}
-	err = unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, int(donefd), &epv)
-	if err != nil {
+	g.mu.Unlock()
+	g.eh(evt)
+}
+
+// close holds back any subsequent events until the gate is opened.
+func (g *eventGate) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.shut {
+		g.shut = true
+		g.hold = len(g.pending)
+	}
+}
+
+// open queues the initial state events, after any events that arrived before
+// the gate was shut and before any held events, and starts passing the
+// pending events to the event handler.
+//
+// Subsequent events are queued behind the pending events, and are passed
+// on as they arrive once the pending events are exhausted.
+func (g *eventGate) open(initial []LineEvent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
 		return
 	}
-	for fd := range fds {
-		epv.Fd = int32(fd)
-		err = unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, fd, &epv)
-		if err != nil {
-			return
-		}
+	if len(initial) != 0 {
+		pending := make([]LineEvent, 0, len(g.pending)+len(initial))
+		pending = append(pending, g.pending[:g.hold]...)
+		pending = append(pending, initial...)
+		g.pending = append(pending, g.pending[g.hold:]...)
 	}
-	w = &watcherV1{
-		watcher: watcher{
-			epfd:   epfd,
-			donefd: donefd,
-			eh:     eh,
-			doneCh: make(chan struct{}),
-		},
-		evtfds: fds,
+	g.hold = 0
+	g.shut = false
+	if !g.flushing && len(g.pending) != 0 {
+		g.flushing = true
+		g.wg.Add(1)
+		go g.flush()
 	}
-	go w.watch()
-	return
 }
 
-func (w *watcherV1) Close() error {
-	unix.Write(w.donefd, []byte{1, 0, 0, 0, 0, 0, 0, 0})
-	<-w.doneCh
-	for fd := range w.evtfds {
-		unix.Close(fd)
+// flush passes the pending events to the event handler, until the pending
+// events are exhausted, or the gate is shut and the events that preceded the
+// shutting are exhausted.
+func (g *eventGate) flush() {
+	defer g.wg.Done()
+	for {
+		g.emu.Lock()
+		g.mu.Lock()
+		if g.closed || len(g.pending) == 0 || (g.shut && g.hold == 0) {
+			g.flushing = false
+			g.mu.Unlock()
+			g.emu.Unlock()
+			return
+		}
+		evt := g.pending[0]
+		g.pending = g.pending[1:]
+		if g.shut {
+			g.hold--
+		}
+		g.mu.Unlock()
+		g.eh(evt)
+		g.emu.Unlock()
 	}
-	unix.Close(w.donefd)
+}
+
+// Close discards any pending events, and waits for the passing of events to
+// the event handler to complete.
+func (g *eventGate) Close() error {
+	g.mu.Lock()
+	g.closed = true
+	g.pending = nil
+	g.mu.Unlock()
+	g.wg.Wait()
 	return nil
 }
 
-func (w *watcherV1) watch() {
-	epollEvents := make([]unix.EpollEvent, len(w.evtfds))
-	defer close(w.doneCh)
-	for {
-		n, err := unix.EpollWait(w.epfd, epollEvents[:], -1)
+// eventBatchSizeV1 is the number of events read from a uAPI v1 event request
+// fd at a time.
+const eventBatchSizeV1 = 16
+
+// eventReaderV1 returns an eventReader for a uAPI v1 event request fd, which
+// only contains events for the line at the given offset.
+func eventReaderV1(offset int) eventReader {
+	buf := make([]uapi.EventData, eventBatchSizeV1)
+	evts := make([]LineEvent, eventBatchSizeV1)
+	return func(fd uintptr) ([]LineEvent, error) {
+		n, err := uapi.ReadEvents(fd, buf)
 		if err != nil {
-			if err == unix.EBADF || err == unix.EINVAL {
-				// fd closed so exit
-				return
-			}
-			if err == unix.EINTR {
-				continue
-			}
-			panic(fmt.Sprintf("EpollWait unexpected error: %v", err))
+			return nil, err
 		}
 		for i := 0; i < n; i++ {
-			ev := epollEvents[i]
-			fd := ev.Fd
-			if fd == int32(w.donefd) {
-				unix.Close(w.epfd)
-				return
+			evts[i] = LineEvent{
+				Offset:    offset,
+				Timestamp: time.Duration(buf[i].Timestamp),
+				Type:      LineEventType(buf[i].ID),
+				Value:     LineEventType(buf[i].ID).value(),
 			}
-			evt, err := uapi.ReadEvent(uintptr(fd))
-			if err != nil {
+		}
+		return evts[:n], nil
+	}
+}
+
+// newEpollEventReaderV1 returns an eventReader for an epoll fd multiplexing
+// uAPI v1 event request fds, which are mapped to the offset of their line.
+//
+// The events read from the ready fds are returned in timestamp order.
+func newEpollEventReaderV1(fds map[int]int) eventReader {
+	readers := make(map[int32]eventReader, len(fds))
+	for fd, offset := range fds {
+		readers[int32(fd)] = eventReaderV1(offset)
+	}
+	ready := make([]unix.EpollEvent, len(fds))
+	var evts []LineEvent
+	return func(epfd uintptr) ([]LineEvent, error) {
+		n, err := unix.EpollWait(int(epfd), ready, 0)
+		if err != nil {
+			return nil, err
+		}
+		evts = evts[:0]
+		for _, ev := range ready[:n] {
+			le, err := readers[ev.Fd](uintptr(ev.Fd))
+			if err == unix.EAGAIN {
 				continue
 			}
-			le := LineEvent{
-				Offset:    w.evtfds[int(fd)],
-				Timestamp: time.Duration(evt.Timestamp),
-				Type:      LineEventType(evt.ID),
+			if err != nil {
+				return nil, err
 			}
-			w.eh(le)
+			evts = append(evts, le...)
+		}
+		if len(evts) == 0 {
+			return nil, unix.EAGAIN
 		}
+		sort.SliceStable(evts, func(i, j int) bool {
+			return evts[i].Timestamp < evts[j].Timestamp
+		})
+		return evts, nil
+	}
+}
+
+// seqTracker counts the edge events from a line request, and detects gaps in
+// the event seqnos, which indicate that the kernel event buffer overflowed and
+// events were discarded.
+type seqTracker struct {
+	oh OverflowHandler
+
+	// mu covers the fields that follow.
+	mu         sync.Mutex
+	seqno      uint32
+	lineSeqno  map[int]uint32
+	events     uint64
+	overflowed uint64
+}
+
+func newSeqTracker(oh OverflowHandler) *seqTracker {
+	return &seqTracker{oh: oh, lineSeqno: map[int]uint32{}}
+}
+
+// wrap returns an EventHandler that checks each event before passing it on
+// to eh.
+func (t *seqTracker) wrap(eh EventHandler) EventHandler {
+	return func(evt LineEvent) {
+		t.check(evt)
+		eh(evt)
+	}
+}
+
+// check updates the counters for the event and calls the overflow handler if
+// events have been discarded since the previous event.
+func (t *seqTracker) check(evt LineEvent) {
+	t.mu.Lock()
+	t.events++
+	if evt.Seqno == 0 {
+		// uAPI v1 - no seqnos to check
+		t.mu.Unlock()
+		return
+	}
+	ovf := LineEventOverflow{
+		Offset:    evt.Offset,
+		Timestamp: evt.Timestamp,
+	}
+	// seqnos start from 1, and a seqno going backwards is treated as a
+	// restart rather than a loss.
+	if evt.Seqno > t.seqno {
+		ovf.Dropped = evt.Seqno - t.seqno - 1
+	}
+	if last := t.lineSeqno[evt.Offset]; evt.LineSeqno > last {
+		ovf.LineDropped = evt.LineSeqno - last - 1
+	}
+	t.seqno = evt.Seqno
+	t.lineSeqno[evt.Offset] = evt.LineSeqno
+	t.overflowed += uint64(ovf.Dropped)
+	t.mu.Unlock()
+	if ovf.Dropped != 0 && t.oh != nil {
+		t.oh(ovf)
+	}
+}
+
+func (t *seqTracker) stats() EventStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return EventStats{Events: t.events, Overflowed: t.overflowed}
+}
+
+// eventChannel forwards events to a channel, closing the channel when either
+// the context is done or the requested lines are closed.
+type eventChannel struct {
+	ctx context.Context
+	ch  chan<- LineEvent
+
+	// closed when the requested lines are being closed
+	done chan struct{}
+
+	// mu covers the closing of ch and the sends to it.
+	mu     sync.Mutex
+	closed bool
+}
+
+func newEventChannel(ctx context.Context, ch chan<- LineEvent) *eventChannel {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &eventChannel{
+		ctx:  ctx,
+		ch:   ch,
+		done: make(chan struct{}),
+	}
+}
+
+// start begins monitoring the context for cancellation.
+func (ec *eventChannel) start() {
+	if ec.ctx.Done() == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-ec.ctx.Done():
+			ec.close()
+		case <-ec.done:
+		}
+	}()
+}
+
+// handle is the EventHandler that forwards events to the channel.
+func (ec *eventChannel) handle(evt LineEvent) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	if ec.closed {
+		return
+	}
+	select {
+	case ec.ch <- evt:
+	case <-ec.ctx.Done():
+		ec.closed = true
+		close(ec.ch)
+	case <-ec.done:
+	}
+}
+
+// stop unblocks any pending send and prevents further sends.
+func (ec *eventChannel) stop() {
+	close(ec.done)
+}
+
+// close closes the channel, if not already closed.
+func (ec *eventChannel) close() {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	if !ec.closed {
+		ec.closed = true
+		close(ec.ch)
 	}
 }